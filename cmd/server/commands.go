@@ -0,0 +1,222 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"wallet-service/internal/wallet"
+)
+
+const usage = `usage: wallet-service [command]
+
+without a command the HTTP server is started
+
+commands:
+  reconcile [-repair -reason REASON [-actor ACTOR]]
+                        compare cached wallet balances with the ledger,
+                        -repair recomputes drifting balances from the ledger
+                        and records each correction with actor and reason
+  check-invariants      verify the double-entry invariants of the ledger
+  snapshot-balances [-from YYYY-MM-DD]
+                        take end of day balance snapshots of every complete
+                        day since the last snapshot, -from backfills from a day
+  api-keys issue -name NAME -scopes SCOPE[,SCOPE...]
+                        issue an API key, the key is printed once
+  api-keys list         list API keys
+  api-keys revoke ID    revoke an API key
+`
+
+// runCommand runs a CLI subcommand and returns the process exit code.
+func runCommand(service *wallet.Service, args []string) int {
+	switch args[0] {
+	case "reconcile":
+		return runReconcile(service, args[1:])
+	case "check-invariants":
+		return runCheckInvariants(service)
+	case "snapshot-balances":
+		return runSnapshotBalances(service, args[1:])
+	case "api-keys":
+		return runAPIKeys(service, args[1:])
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		return 2
+	}
+}
+
+// runReconcile prints the reconciliation report as JSON. It exits with 1
+// when drift is left, found without -repair or not repairable, so it can
+// gate a scheduled job.
+func runReconcile(service *wallet.Service, args []string) int {
+	fs := flag.NewFlagSet("reconcile", flag.ContinueOnError)
+	repair := fs.Bool("repair", false, "recompute drifting balances from the ledger")
+	actor := fs.String("actor", "cli", "who the repair is recorded for")
+	reason := fs.String("reason", "", "why the repair is made, required with -repair")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	if *repair && strings.TrimSpace(*reason) == "" {
+		fmt.Fprintln(os.Stderr, "reconcile -repair requires -reason")
+		return 2
+	}
+
+	var (
+		report wallet.ReconciliationReport
+		err    error
+	)
+	if *repair {
+		report, err = service.RepairDrift(context.Background(), *actor, *reason)
+	} else {
+		report, err = service.Reconcile(context.Background())
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "reconcile failed: %v\n", err)
+		return 1
+	}
+
+	if err := printJSON(report); err != nil {
+		return 1
+	}
+
+	if report.Unrepaired > 0 {
+		return 1
+	}
+
+	return 0
+}
+
+// runCheckInvariants prints the invariant report as JSON and exits with 1
+// when any invariant is violated.
+func runCheckInvariants(service *wallet.Service) int {
+	report, err := service.CheckInvariants(context.Background())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "check invariants failed: %v\n", err)
+		return 1
+	}
+
+	if err := printJSON(report); err != nil {
+		return 1
+	}
+
+	if !report.OK {
+		return 1
+	}
+
+	return 0
+}
+
+// runSnapshotBalances takes the missing end of day balance snapshots.
+// Existing snapshots are kept, so a backfill over them is safe.
+func runSnapshotBalances(service *wallet.Service, args []string) int {
+	fs := flag.NewFlagSet("snapshot-balances", flag.ContinueOnError)
+	fromStr := fs.String("from", "", "first day to snapshot, YYYY-MM-DD")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	var from *time.Time
+	if *fromStr != "" {
+		day, err := time.Parse("2006-01-02", *fromStr)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid -from %q, expected YYYY-MM-DD\n", *fromStr)
+			return 2
+		}
+		from = &day
+	}
+
+	days, written, err := service.SnapshotBalances(context.Background(), from)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "snapshot balances failed: %v\n", err)
+		return 1
+	}
+
+	fmt.Printf("snapshotted %d days, %d snapshots written\n", days, written)
+
+	return 0
+}
+
+// runAPIKeys manages API keys. It is how the first admin:keys key is issued,
+// since the HTTP endpoints already require one.
+func runAPIKeys(service *wallet.Service, args []string) int {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, usage)
+		return 2
+	}
+
+	ctx := context.Background()
+
+	switch args[0] {
+	case "issue":
+		fs := flag.NewFlagSet("api-keys issue", flag.ContinueOnError)
+		name := fs.String("name", "", "name of the key owner")
+		scopes := fs.String("scopes", "", "comma separated scopes, * grants every scope")
+		if err := fs.Parse(args[1:]); err != nil {
+			return 2
+		}
+
+		key, err := service.IssueAPIKey(ctx, *name, strings.Split(*scopes, ","))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "issue api key failed: %v\n", err)
+			return 1
+		}
+
+		if err := printJSON(key); err != nil {
+			return 1
+		}
+
+	case "list":
+		keys, err := service.ListAPIKeys(ctx)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "list api keys failed: %v\n", err)
+			return 1
+		}
+
+		if err := printJSON(keys); err != nil {
+			return 1
+		}
+
+	case "revoke":
+		if len(args) != 2 {
+			fmt.Fprint(os.Stderr, usage)
+			return 2
+		}
+
+		id, err := uuid.Parse(args[1])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid key id %q\n", args[1])
+			return 2
+		}
+
+		key, err := service.RevokeAPIKey(ctx, id)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "revoke api key failed: %v\n", err)
+			return 1
+		}
+
+		if err := printJSON(key); err != nil {
+			return 1
+		}
+
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		return 2
+	}
+
+	return 0
+}
+
+func printJSON(v any) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		fmt.Fprintf(os.Stderr, "encode report: %v\n", err)
+		return err
+	}
+	return nil
+}
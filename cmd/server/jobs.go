@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"time"
+
+	"wallet-service/internal/jobs"
+	"wallet-service/internal/wallet"
+)
+
+// startJobs schedules the background jobs enabled through the environment.
+// Intervals use time.ParseDuration syntax such as "1h", unset disables a job.
+func startJobs(ctx context.Context, service *wallet.Service) {
+	if interval := jobInterval("INVARIANT_CHECK_INTERVAL"); interval > 0 {
+		jobs.Every(ctx, "check-invariants", interval, checkInvariantsJob(service))
+	}
+	if interval := jobInterval("BALANCE_SNAPSHOT_INTERVAL"); interval > 0 {
+		jobs.Every(ctx, "snapshot-balances", interval, snapshotBalancesJob(service))
+	}
+}
+
+func jobInterval(env string) time.Duration {
+	v := os.Getenv(env)
+	if v == "" {
+		return 0
+	}
+
+	interval, err := time.ParseDuration(v)
+	if err != nil || interval <= 0 {
+		log.Fatalf("invalid %s: %q", env, v)
+	}
+
+	return interval
+}
+
+// checkInvariantsJob logs every violated ledger invariant.
+func checkInvariantsJob(service *wallet.Service) func(context.Context) error {
+	return func(ctx context.Context) error {
+		report, err := service.CheckInvariants(ctx)
+		if err != nil {
+			return err
+		}
+		if report.OK {
+			return nil
+		}
+
+		log.Printf(
+			"ledger invariants violated: unbalanced=%d orphan=%d",
+			report.UnbalancedCount,
+			report.OrphanCount,
+		)
+		for _, b := range report.Assets {
+			if b.Net != 0 || b.CachedTotal != 0 {
+				log.Printf(
+					"asset %s: net=%d cached_total=%d unbacked=%d",
+					b.Asset,
+					b.Net,
+					b.CachedTotal,
+					b.UnbackedBalance,
+				)
+			}
+		}
+
+		return nil
+	}
+}
+
+// snapshotBalancesJob snapshots every complete day since the last snapshot,
+// so a missed run is caught up by the next one.
+func snapshotBalancesJob(service *wallet.Service) func(context.Context) error {
+	return func(ctx context.Context) error {
+		days, written, err := service.SnapshotBalances(ctx, nil)
+		if err != nil {
+			return err
+		}
+		if days > 0 {
+			log.Printf("balance snapshots: days=%d snapshots=%d", days, written)
+		}
+		return nil
+	}
+}
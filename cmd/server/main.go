@@ -3,11 +3,13 @@ package main
 import (
 	"context"
 	"log"
+	"os"
 
 	"github.com/gin-gonic/gin"
 
 	"wallet-service/internal/api"
 	"wallet-service/internal/db"
+	"wallet-service/internal/stream"
 	"wallet-service/internal/wallet"
 )
 
@@ -28,29 +30,129 @@ func main() {
 	// Wire dependencies
 	repo := wallet.NewRepository(pool)
 	service := wallet.NewService(repo)
-	handler := api.NewHandler(service)
+	events := stream.NewHub(pool)
+	limiter := newLimiter(context.Background(), pool)
+	handler := api.NewHandler(service, events, newTokenVerifier(), limiter)
+
+	// CLI subcommands share the wiring above
+	if len(os.Args) > 1 {
+		os.Exit(runCommand(service, os.Args[1:]))
+	}
+
+	// Scheduled jobs
+	startJobs(context.Background(), service)
+	startOutbox(context.Background(), repo)
+	events.Run(context.Background())
 
 	// Setup router
 	r := gin.Default()
 
+	// every route requires an API key with the scope it declares
+	scope := handler.RequireScope
+
+	// these routes also take end-user tokens, limited to the user's wallets
+	userOrScope := handler.RequireScopeOrUser
+
 	// Wallet routes
-	r.GET("/wallets/:wallet_id/balance", handler.GetBalance)
+	r.GET("/wallets/:wallet_id/balance", userOrScope(wallet.ScopeReadLedger), handler.GetBalance)
+
+	r.GET("/wallets/:wallet_id/statement", userOrScope(wallet.ScopeReadLedger), handler.GetStatement)
+
+	r.GET("/wallets/:wallet_id/balance-history", userOrScope(wallet.ScopeReadLedger), handler.GetBalanceHistory)
+
+	r.GET("/wallets/:wallet_id/events", userOrScope(wallet.ScopeReadLedger), handler.StreamWalletEvents)
+
+	r.GET("/wallets/:wallet_id/limits", userOrScope(wallet.ScopeReadLedger), handler.GetWalletLimits)
+
+	r.POST("/wallets/:wallet_id/topup", scope(wallet.ScopeWalletTopup), handler.TopUpWallet)
+
+	r.POST("/wallets/:wallet_id/bonus", scope(wallet.ScopeWalletBonus), handler.GrantBonus)
+
+	r.POST("/wallets/:wallet_id/spend", userOrScope(wallet.ScopeWalletSpend), handler.Spend)
 
-	r.POST("/wallets/:wallet_id/topup", handler.TopUpWallet)
+	r.POST("/wallets/:wallet_id/transfer", userOrScope(wallet.ScopeWalletTransfer), handler.Transfer)
 
-	r.POST("/wallets/:wallet_id/bonus", handler.GrantBonus)
+	r.POST("/wallets/:wallet_id/holds", scope(wallet.ScopeWalletSpend), handler.ReserveHold)
 
-	r.POST("/wallets/:wallet_id/spend", handler.Spend)
+	r.GET("/holds/:hold_id", scope(wallet.ScopeReadLedger), handler.GetHold)
 
-	r.POST("/users", handler.CreateUser)
+	r.POST("/holds/:hold_id/capture", scope(wallet.ScopeWalletSpend), handler.CaptureHold)
 
-	r.POST("/wallets", handler.CreateWallet)
+	r.POST("/holds/:hold_id/void", scope(wallet.ScopeWalletSpend), handler.VoidHold)
+
+	r.POST("/users", scope(wallet.ScopeAdminWallets), handler.CreateUser)
+
+	r.POST("/wallets", scope(wallet.ScopeAdminWallets), handler.CreateWallet)
 	
-	r.POST("/assets", handler.CreateAsset)
+	r.POST("/assets", scope(wallet.ScopeAdminAssets), handler.CreateAsset)
+
+	r.POST("/assets/:code/sweep", scope(wallet.ScopeAdminLedger), handler.SweepRevenue)
+
+	r.POST("/assets/:code/mint", scope(wallet.ScopeAdminSupply), handler.MintSupply)
+
+	r.POST("/assets/:code/burn", scope(wallet.ScopeAdminSupply), handler.BurnSupply)
+
+	r.PUT("/assets/:code/max-supply", scope(wallet.ScopeAdminSupply), handler.SetMaxSupply)
 
-	r.GET("/transactions", handler.GetTransactions)
+	r.GET("/assets/:code/supply", scope(wallet.ScopeReadLedger), handler.GetSupply)
+
+	r.GET("/assets/:code/balances", scope(wallet.ScopeReadLedger), handler.GetAssetBalances)
+
+	r.GET("/transactions", scope(wallet.ScopeReadLedger), handler.GetTransactions)
+
+	r.POST("/transactions/:id/reverse", scope(wallet.ScopeAdminLedger), handler.ReverseTransaction)
 	
-	r.GET("/ledger-entries", handler.GetLedgerEntries)
+	r.GET("/ledger-entries", scope(wallet.ScopeReadLedger), handler.GetLedgerEntries)
+
+	// Webhook routes
+	r.POST("/webhooks", scope(wallet.ScopeAdminWebhooks), handler.CreateWebhook)
+
+	r.GET("/webhooks", scope(wallet.ScopeAdminWebhooks), handler.ListWebhooks)
+
+	r.DELETE("/webhooks/:webhook_id", scope(wallet.ScopeAdminWebhooks), handler.DeleteWebhook)
+
+	r.GET("/webhooks/:webhook_id/deliveries", scope(wallet.ScopeAdminWebhooks), handler.ListWebhookDeliveries)
+
+	r.GET("/webhook-deliveries/:delivery_id", scope(wallet.ScopeAdminWebhooks), handler.GetWebhookDelivery)
+
+	r.POST("/webhook-deliveries/:delivery_id/replay", scope(wallet.ScopeAdminWebhooks), handler.ReplayWebhookDelivery)
+
+	// Admin routes
+	r.GET("/admin/reconciliation", scope(wallet.ScopeAdminLedger), handler.GetReconciliation)
+
+	r.POST("/admin/reconciliation/repair", scope(wallet.ScopeAdminLedger), handler.RepairReconciliation)
+
+	r.POST("/admin/wallets/:wallet_id/ledger-adjustment", scope(wallet.ScopeAdminLedger), handler.AdjustLedger)
+
+	r.GET("/admin/invariants", scope(wallet.ScopeAdminLedger), handler.GetInvariants)
+
+	r.GET("/admin/negative-balances", scope(wallet.ScopeAdminLedger), handler.ListNegativeBalances)
+
+	r.POST("/admin/wallets/:wallet_id/freeze", scope(wallet.ScopeAdminWallets), handler.FreezeWallet)
+
+	r.POST("/admin/wallets/:wallet_id/unfreeze", scope(wallet.ScopeAdminWallets), handler.UnfreezeWallet)
+
+	r.POST("/admin/wallets/:wallet_id/close", scope(wallet.ScopeAdminWallets), handler.CloseWallet)
+
+	r.PUT("/admin/wallets/:wallet_id/overdraft", scope(wallet.ScopeAdminWallets), handler.SetOverdraftLimit)
+
+	r.GET("/admin/wallets/:wallet_id/status-history", scope(wallet.ScopeAdminWallets), handler.GetWalletStatusHistory)
+
+	r.POST("/admin/users/:user_id/freeze", scope(wallet.ScopeAdminWallets), handler.FreezeUser)
+
+	r.POST("/admin/users/:user_id/unfreeze", scope(wallet.ScopeAdminWallets), handler.UnfreezeUser)
+
+	r.PUT("/admin/limits", scope(wallet.ScopeAdminLimits), handler.SetVelocityLimit)
+
+	r.GET("/admin/limits", scope(wallet.ScopeAdminLimits), handler.ListVelocityLimits)
+
+	r.DELETE("/admin/limits/:limit_id", scope(wallet.ScopeAdminLimits), handler.DeleteVelocityLimit)
+
+	r.POST("/admin/api-keys", scope(wallet.ScopeAdminKeys), handler.IssueAPIKey)
+
+	r.GET("/admin/api-keys", scope(wallet.ScopeAdminKeys), handler.ListAPIKeys)
+
+	r.DELETE("/admin/api-keys/:key_id", scope(wallet.ScopeAdminKeys), handler.RevokeAPIKey)
 
 	log.Println("Server starting on :8080...")
 	if err := r.Run(":8080"); err != nil {
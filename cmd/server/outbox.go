@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"wallet-service/internal/jobs"
+	"wallet-service/internal/outbox"
+	"wallet-service/internal/wallet"
+	"wallet-service/internal/webhook"
+)
+
+// startOutbox runs the outbox dispatcher, which queues webhook deliveries
+// and, when OUTBOX_SINK is set, publishes to that sink as well. The sink is
+// "stdout", "file:<path>" or an http(s) URL. A second job sends the queued
+// webhook deliveries.
+func startOutbox(ctx context.Context, repo *wallet.Repository) {
+	sinks := outbox.MultiSink{webhook.NewFanoutSink(repo)}
+
+	if target := os.Getenv("OUTBOX_SINK"); target != "" {
+		sink, err := newSink(target)
+		if err != nil {
+			log.Fatalf("invalid OUTBOX_SINK: %v", err)
+		}
+		sinks = append(sinks, sink)
+	}
+
+	interval := jobInterval("OUTBOX_POLL_INTERVAL")
+	if interval == 0 {
+		interval = time.Second
+	}
+
+	dispatcher := outbox.NewDispatcher(repo, sinks)
+	jobs.Every(ctx, "outbox-dispatch", interval, dispatcher.Dispatch)
+
+	deliverer := webhook.NewDeliverer(repo, nil)
+	jobs.Every(ctx, "webhook-deliver", interval, deliverer.Deliver)
+}
+
+func newSink(target string) (outbox.Sink, error) {
+	switch {
+	case target == "stdout":
+		return outbox.NewWriterSink(os.Stdout), nil
+	case strings.HasPrefix(target, "file:"):
+		return outbox.NewFileSink(strings.TrimPrefix(target, "file:"))
+	case strings.HasPrefix(target, "http://"), strings.HasPrefix(target, "https://"):
+		return outbox.NewHTTPSink(target), nil
+	default:
+		return nil, fmt.Errorf("unknown sink %q", target)
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"wallet-service/internal/jobs"
+	"wallet-service/internal/ratelimit"
+)
+
+// newLimiter configures rate limiting from the environment, it returns nil
+// when no limit is set. Limits use ratelimit.ParseRule syntax:
+//
+//	RATE_LIMIT_CLIENT=100/1m     per API key or end user, per route
+//	RATE_LIMIT_WALLET=30/1m      per wallet, per route
+//	RATE_LIMIT_ROUTE=1000/1m     per route, shared by every caller
+//	RATE_LIMIT_ROUTES=POST /wallets/:wallet_id/spend client=10/1s wallet=5/1s; ...
+//	RATE_LIMIT_STORE=memory|postgres
+func newLimiter(ctx context.Context, pool *pgxpool.Pool) *ratelimit.Limiter {
+	var def ratelimit.Policy
+	var err error
+
+	def.Client, err = envRule("RATE_LIMIT_CLIENT")
+	if err == nil {
+		def.Wallet, err = envRule("RATE_LIMIT_WALLET")
+	}
+	if err == nil {
+		def.Route, err = envRule("RATE_LIMIT_ROUTE")
+	}
+	if err != nil {
+		log.Fatalf("invalid rate limit: %v", err)
+	}
+
+	routes, err := parseRoutePolicies(os.Getenv("RATE_LIMIT_ROUTES"))
+	if err != nil {
+		log.Fatalf("invalid RATE_LIMIT_ROUTES: %v", err)
+	}
+
+	if def.Client == nil && def.Wallet == nil && def.Route == nil && len(routes) == 0 {
+		return nil
+	}
+
+	var store ratelimit.Store
+
+	switch os.Getenv("RATE_LIMIT_STORE") {
+	case "", "memory":
+		store = ratelimit.NewMemoryStore()
+	case "postgres":
+		pg := ratelimit.NewPostgresStore(pool)
+		jobs.Every(ctx, "rate-limit-cleanup", 10*time.Minute, func(ctx context.Context) error {
+			return pg.Cleanup(ctx, 24*time.Hour)
+		})
+		store = pg
+	default:
+		log.Fatalf("invalid RATE_LIMIT_STORE: %q", os.Getenv("RATE_LIMIT_STORE"))
+	}
+
+	return ratelimit.NewLimiter(store, def, routes)
+}
+
+func envRule(env string) (*ratelimit.Rule, error) {
+	v := os.Getenv(env)
+	if v == "" {
+		return nil, nil
+	}
+
+	rule, err := ratelimit.ParseRule(v)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", env, err)
+	}
+
+	return &rule, nil
+}
+
+// parseRoutePolicies parses "; " separated route overrides, each a method,
+// a route path as registered and one or more client=, wallet= or route=
+// rules.
+func parseRoutePolicies(s string) (map[string]ratelimit.Policy, error) {
+	routes := make(map[string]ratelimit.Policy)
+
+	for _, entry := range strings.Split(s, ";") {
+		fields := strings.Fields(entry)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) < 3 {
+			return nil, fmt.Errorf("%q: expected METHOD PATH and at least one rule", entry)
+		}
+
+		var p ratelimit.Policy
+
+		for _, f := range fields[2:] {
+			kind, v, ok := strings.Cut(f, "=")
+			if !ok {
+				return nil, fmt.Errorf("%q: expected kind=rule", f)
+			}
+
+			rule, err := ratelimit.ParseRule(v)
+			if err != nil {
+				return nil, err
+			}
+
+			switch kind {
+			case "client":
+				p.Client = &rule
+			case "wallet":
+				p.Wallet = &rule
+			case "route":
+				p.Route = &rule
+			default:
+				return nil, fmt.Errorf("%q: unknown kind %q", f, kind)
+			}
+		}
+
+		routes[strings.ToUpper(fields[0])+" "+fields[1]] = p
+	}
+
+	return routes, nil
+}
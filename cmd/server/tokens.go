@@ -0,0 +1,38 @@
+package main
+
+import (
+	"log"
+	"os"
+
+	"wallet-service/internal/auth"
+)
+
+// newTokenVerifier configures end-user token verification from the
+// environment. It returns nil, refusing end-user tokens, when neither
+// JWT_HS256_SECRET nor JWT_RS256_PUBLIC_KEY_FILE is set.
+func newTokenVerifier() *auth.Verifier {
+	secret := os.Getenv("JWT_HS256_SECRET")
+	keyFile := os.Getenv("JWT_RS256_PUBLIC_KEY_FILE")
+	if secret == "" && keyFile == "" {
+		return nil
+	}
+
+	var publicKey []byte
+	if keyFile != "" {
+		b, err := os.ReadFile(keyFile)
+		if err != nil {
+			log.Fatalf("read JWT_RS256_PUBLIC_KEY_FILE: %v", err)
+		}
+		publicKey = b
+	}
+
+	verifier, err := auth.NewVerifier([]byte(secret), publicKey)
+	if err != nil {
+		log.Fatalf("invalid jwt configuration: %v", err)
+	}
+
+	verifier.Issuer = os.Getenv("JWT_ISSUER")
+	verifier.Audience = os.Getenv("JWT_AUDIENCE")
+
+	return verifier
+}
@@ -0,0 +1,175 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"wallet-service/internal/auth"
+	"wallet-service/internal/wallet"
+)
+
+// apiKeyContextKey holds the authenticated wallet.APIKey of a request.
+const apiKeyContextKey = "api_key"
+
+// RequireScope authenticates the API key of a request, sent as a bearer
+// token or in X-API-Key, and rejects keys without scope. End-user tokens are
+// refused.
+func (h *Handler) RequireScope(scope string) gin.HandlerFunc {
+	return h.authenticate(scope, false)
+}
+
+// RequireScopeOrUser also accepts end-user tokens, which act for their
+// subject only. Handlers behind it must call authorizeWallet.
+func (h *Handler) RequireScopeOrUser(scope string) gin.HandlerFunc {
+	return h.authenticate(scope, true)
+}
+
+func (h *Handler) authenticate(scope string, allowUsers bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		raw := credentials(c)
+		if raw == "" {
+			unauthenticated(c, wallet.ErrUnauthenticated)
+			return
+		}
+
+		if !strings.HasPrefix(raw, wallet.APIKeyPrefix) {
+			h.authenticateUser(c, raw, allowUsers)
+			return
+		}
+
+		key, err := h.walletService.AuthenticateAPIKey(c.Request.Context(), raw)
+		if err != nil {
+			unauthenticated(c, err)
+			return
+		}
+
+		if !key.HasScope(scope) {
+			writeError(c, fmt.Errorf("%w: %s", wallet.ErrForbidden, scope))
+			return
+		}
+
+		c.Set(apiKeyContextKey, key)
+
+		if !h.rateLimit(c, "key:"+key.ID.String()) {
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// authenticateUser verifies an end-user JWT and puts its subject, a user
+// id, in the request context.
+func (h *Handler) authenticateUser(c *gin.Context, raw string, allowed bool) {
+	if h.tokens == nil {
+		unauthenticated(c, wallet.ErrUnauthenticated)
+		return
+	}
+
+	claims, err := h.tokens.Verify(raw)
+	if err != nil {
+		unauthenticated(c, wallet.ErrUnauthenticated)
+		return
+	}
+
+	userID, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		unauthenticated(c, wallet.ErrUnauthenticated)
+		return
+	}
+
+	if !allowed {
+		writeError(c, fmt.Errorf("%w: route requires an API key", wallet.ErrForbidden))
+		return
+	}
+
+	c.Request = c.Request.WithContext(auth.WithSubject(c.Request.Context(), userID))
+
+	if !h.rateLimit(c, "user:"+userID.String()) {
+		return
+	}
+
+	c.Next()
+}
+
+// authorizeWallet writes an error and returns false when an end-user
+// request targets a wallet of another user.
+func (h *Handler) authorizeWallet(c *gin.Context, walletID uuid.UUID) bool {
+	if err := h.walletService.AuthorizeWallet(c.Request.Context(), walletID); err != nil {
+		writeError(c, err)
+		return false
+	}
+	return true
+}
+
+func credentials(c *gin.Context) string {
+	if v := c.GetHeader("X-API-Key"); v != "" {
+		return v
+	}
+
+	scheme, token, ok := strings.Cut(c.GetHeader("Authorization"), " ")
+	if ok && strings.EqualFold(scheme, "Bearer") {
+		return strings.TrimSpace(token)
+	}
+
+	return ""
+}
+
+func unauthenticated(c *gin.Context, err error) {
+	if errors.Is(err, wallet.ErrUnauthenticated) {
+		c.Header("WWW-Authenticate", `Bearer realm="wallet-service"`)
+	}
+	writeError(c, err)
+}
+
+type IssueAPIKeyRequest struct {
+	Name   string   `json:"name" binding:"required"`
+	Scopes []string `json:"scopes" binding:"required"`
+}
+
+func (h *Handler) IssueAPIKey(c *gin.Context) {
+	var req IssueAPIKeyRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		badRequest(c, err.Error())
+		return
+	}
+
+	key, err := h.walletService.IssueAPIKey(c.Request.Context(), req.Name, req.Scopes)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, key)
+}
+
+func (h *Handler) ListAPIKeys(c *gin.Context) {
+	keys, err := h.walletService.ListAPIKeys(c.Request.Context())
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": keys})
+}
+
+func (h *Handler) RevokeAPIKey(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("key_id"))
+	if err != nil {
+		badRequest(c, "invalid key id")
+		return
+	}
+
+	key, err := h.walletService.RevokeAPIKey(c.Request.Context(), id)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, key)
+}
@@ -0,0 +1,207 @@
+package api
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"wallet-service/internal/wallet"
+)
+
+const problemContentType = "application/problem+json"
+
+// problem is an RFC 7807 problem details body. Code is a stable machine
+// readable identifier clients can branch on, Extra holds extension members.
+type problem struct {
+	Type     string
+	Title    string
+	Status   int
+	Detail   string
+	Instance string
+	Code     string
+	Extra    gin.H
+}
+
+type errorMapping struct {
+	err    error
+	status int
+	code   string
+	title  string
+}
+
+// errorMappings is checked in order, the first match wins.
+var errorMappings = []errorMapping{
+	{wallet.ErrWalletNotFound, http.StatusNotFound, "wallet_not_found", "Wallet not found"},
+	{wallet.ErrUserNotFound, http.StatusNotFound, "user_not_found", "User not found"},
+	{wallet.ErrAssetNotFound, http.StatusNotFound, "asset_not_found", "Asset not found"},
+	{wallet.ErrUnsupportedAsset, http.StatusBadRequest, "unsupported_asset", "Unsupported asset"},
+	{wallet.ErrAssetMismatch, http.StatusUnprocessableEntity, "asset_mismatch", "Wallet asset mismatch"},
+	{wallet.ErrInsufficientFunds, http.StatusUnprocessableEntity, "insufficient_funds", "Insufficient funds"},
+	{wallet.ErrInvalidAmount, http.StatusBadRequest, "invalid_amount", "Invalid amount"},
+	{wallet.ErrInvalidPosting, http.StatusBadRequest, "invalid_posting", "Invalid journal posting"},
+	{wallet.ErrUnbalancedJournal, http.StatusUnprocessableEntity, "unbalanced_journal", "Unbalanced journal"},
+	{wallet.ErrIdempotencyConflict, http.StatusConflict, "idempotency_conflict", "Idempotency conflict"},
+	{wallet.ErrDuplicateReference, http.StatusConflict, "duplicate_reference", "Duplicate reference"},
+	{wallet.ErrDuplicateAsset, http.StatusConflict, "duplicate_asset", "Asset already exists"},
+	{wallet.ErrDuplicateWallet, http.StatusConflict, "duplicate_wallet", "Wallet already exists"},
+	{wallet.ErrWalletFrozen, http.StatusForbidden, "wallet_frozen", "Wallet is frozen"},
+	{wallet.ErrWalletClosed, http.StatusForbidden, "wallet_closed", "Wallet is closed"},
+	{wallet.ErrWalletNotEmpty, http.StatusConflict, "wallet_not_empty", "Wallet is not empty"},
+	{wallet.ErrInvalidStatusChange, http.StatusBadRequest, "invalid_status_change", "Invalid wallet status change"},
+	{wallet.ErrSameWallet, http.StatusBadRequest, "same_wallet", "Same source and destination wallet"},
+	{wallet.ErrNotUserWallet, http.StatusBadRequest, "not_user_wallet", "Not a user wallet"},
+	{wallet.ErrNothingToSweep, http.StatusUnprocessableEntity, "nothing_to_sweep", "Nothing to sweep"},
+	{wallet.ErrTransactionNotFound, http.StatusNotFound, "transaction_not_found", "Transaction not found"},
+	{wallet.ErrNotReversible, http.StatusUnprocessableEntity, "not_reversible", "Transaction cannot be reversed"},
+	{wallet.ErrReversalExceedsRemaining, http.StatusUnprocessableEntity, "reversal_exceeds_remaining", "Reversal exceeds remaining amount"},
+	{wallet.ErrPartialReversalUnsupported, http.StatusUnprocessableEntity, "partial_reversal_unsupported", "Partial reversal unsupported"},
+	{wallet.ErrHoldNotFound, http.StatusNotFound, "hold_not_found", "Hold not found"},
+	{wallet.ErrHoldNotActive, http.StatusConflict, "hold_not_active", "Hold is not active"},
+	{wallet.ErrHoldExpired, http.StatusConflict, "hold_expired", "Hold has expired"},
+	{wallet.ErrCaptureExceedsHold, http.StatusUnprocessableEntity, "capture_exceeds_hold", "Capture exceeds hold"},
+	{wallet.ErrInvalidHoldTTL, http.StatusBadRequest, "invalid_hold_ttl", "Invalid hold ttl"},
+	{wallet.ErrInvalidCursor, http.StatusBadRequest, "invalid_cursor", "Invalid cursor"},
+	{wallet.ErrInvalidFilter, http.StatusBadRequest, "invalid_filter", "Invalid filter"},
+	{wallet.ErrNotRepairable, http.StatusUnprocessableEntity, "not_repairable", "Drift cannot be repaired"},
+	{wallet.ErrInvalidRepair, http.StatusBadRequest, "invalid_repair", "Invalid repair request"},
+	{wallet.ErrUnsupportedInterval, http.StatusBadRequest, "unsupported_interval", "Unsupported interval"},
+	{wallet.ErrInvalidHistoryRange, http.StatusBadRequest, "invalid_history_range", "Invalid history range"},
+	{wallet.ErrInvalidWebhook, http.StatusBadRequest, "invalid_webhook", "Invalid webhook"},
+	{wallet.ErrWebhookNotFound, http.StatusNotFound, "webhook_not_found", "Webhook not found"},
+	{wallet.ErrWebhookDeliveryNotFound, http.StatusNotFound, "webhook_delivery_not_found", "Webhook delivery not found"},
+	{wallet.ErrUnauthenticated, http.StatusUnauthorized, "unauthenticated", "Unauthenticated"},
+	{wallet.ErrForbidden, http.StatusForbidden, "insufficient_scope", "Insufficient scope"},
+	{wallet.ErrWalletForbidden, http.StatusForbidden, "wallet_forbidden", "Wallet belongs to another user"},
+	{wallet.ErrInvalidAPIKey, http.StatusBadRequest, "invalid_api_key", "Invalid API key"},
+	{wallet.ErrAPIKeyNotFound, http.StatusNotFound, "api_key_not_found", "API key not found"},
+	{wallet.ErrRateLimited, http.StatusTooManyRequests, "rate_limited", "Rate limit exceeded"},
+	{wallet.ErrInvalidLimit, http.StatusBadRequest, "invalid_limit", "Invalid velocity limit"},
+	{wallet.ErrLimitNotFound, http.StatusNotFound, "limit_not_found", "Velocity limit not found"},
+	{wallet.ErrLimitExceeded, http.StatusUnprocessableEntity, "limit_exceeded", "Velocity limit exceeded"},
+	{wallet.ErrInvalidOverdraft, http.StatusBadRequest, "invalid_overdraft", "Invalid overdraft limit"},
+	{wallet.ErrOverdraftInUse, http.StatusConflict, "overdraft_in_use", "Overdraft in use"},
+	{wallet.ErrSupplyCapExceeded, http.StatusUnprocessableEntity, "supply_cap_exceeded", "Max supply exceeded"},
+	{wallet.ErrInvalidMaxSupply, http.StatusBadRequest, "invalid_max_supply", "Invalid max supply"},
+	{wallet.ErrRetriesExhausted, http.StatusServiceUnavailable, "retries_exhausted", "Operation failed after retries"},
+}
+
+func problemType(code string) string {
+	return "/problems/" + code
+}
+
+func writeProblem(c *gin.Context, p problem) {
+	body := gin.H{
+		"type":   p.Type,
+		"title":  p.Title,
+		"status": p.Status,
+		"code":   p.Code,
+	}
+	if p.Detail != "" {
+		body["detail"] = p.Detail
+	}
+	if p.Instance != "" {
+		body["instance"] = p.Instance
+	}
+	for k, v := range p.Extra {
+		body[k] = v
+	}
+
+	c.Header("Content-Type", problemContentType)
+	c.AbortWithStatusJSON(p.Status, body)
+}
+
+// badRequest reports a malformed request, such as a bind failure or an
+// unparsable path parameter.
+func badRequest(c *gin.Context, detail string) {
+	writeProblem(c, problem{
+		Type:     problemType("invalid_request"),
+		Title:    "Invalid request",
+		Status:   http.StatusBadRequest,
+		Detail:   detail,
+		Instance: c.Request.URL.Path,
+		Code:     "invalid_request",
+	})
+}
+
+// writeError maps an error returned by the wallet service to a problem
+// response. Unknown errors are logged and reported as a generic 500.
+func writeError(c *gin.Context, err error) {
+	for _, m := range errorMappings {
+		if !errors.Is(err, m.err) {
+			continue
+		}
+
+		writeProblem(c, problem{
+			Type:     problemType(m.code),
+			Title:    m.title,
+			Status:   m.status,
+			Detail:   err.Error(),
+			Instance: c.Request.URL.Path,
+			Code:     m.code,
+			Extra:    problemExtra(err),
+		})
+		return
+	}
+
+	log.Printf("%s %s: %v", c.Request.Method, c.Request.URL.Path, err)
+
+	writeProblem(c, problem{
+		Type:     problemType("internal_error"),
+		Title:    "Internal server error",
+		Status:   http.StatusInternalServerError,
+		Instance: c.Request.URL.Path,
+		Code:     "internal_error",
+	})
+}
+
+// problemExtra exposes the fields of structured wallet errors as problem
+// extension members.
+func problemExtra(err error) gin.H {
+	var funds *wallet.InsufficientFundsError
+	if errors.As(err, &funds) {
+		return gin.H{
+			"wallet_id":       funds.WalletID,
+			"balance":         funds.Balance,
+			"overdraft_limit": funds.OverdraftLimit,
+			"requested":       funds.Requested,
+		}
+	}
+
+	var limit *wallet.LimitExceededError
+	if errors.As(err, &limit) {
+		return gin.H{
+			"wallet_id":        limit.WalletID,
+			"limit_id":         limit.Limit.ID,
+			"tx_type":          limit.Limit.TxType,
+			"direction":        limit.Limit.Direction,
+			"window":           limit.Limit.Window,
+			"max_amount":       limit.Limit.MaxAmount,
+			"max_count":        limit.Limit.MaxCount,
+			"remaining_amount": limit.Limit.RemainingAmount,
+			"remaining_count":  limit.Limit.RemainingCount,
+			"requested":        limit.Requested,
+		}
+	}
+
+	var supply *wallet.SupplyCapError
+	if errors.As(err, &supply) {
+		return gin.H{
+			"asset":      supply.Asset,
+			"max_supply": supply.MaxSupply,
+			"issued":     supply.Issued,
+			"requested":  supply.Requested,
+		}
+	}
+
+	var mismatch *wallet.AssetMismatchError
+	if errors.As(err, &mismatch) {
+		return gin.H{
+			"wallet_id":       mismatch.WalletID,
+			"wallet_asset":    mismatch.WalletAsset,
+			"requested_asset": mismatch.RequestedAsset,
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,112 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	eventBatchSize = 100
+
+	// heartbeatInterval keeps proxies from closing an idle stream, each
+	// heartbeat also rereads the ledger in case a notification was lost.
+	heartbeatInterval = 15 * time.Second
+)
+
+// StreamWalletEvents streams the ledger events of a wallet as Server-Sent
+// Events. A new stream starts with a "balance" event, then sends a "ledger"
+// event per transaction touching the wallet. Every event id is an outbox
+// sequence, a client reconnecting with Last-Event-ID (or ?last_event_id=)
+// gets every event after it.
+func (h *Handler) StreamWalletEvents(c *gin.Context) {
+	walletID, err := uuid.Parse(c.Param("wallet_id"))
+	if err != nil {
+		badRequest(c, "invalid wallet_id")
+		return
+	}
+
+	if !h.authorizeWallet(c, walletID) {
+		return
+	}
+
+	lastEventID := c.GetHeader("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = c.Query("last_event_id")
+	}
+
+	var after int64
+	resume := lastEventID != ""
+	if resume {
+		after, err = strconv.ParseInt(lastEventID, 10, 64)
+		if err != nil || after < 0 {
+			badRequest(c, "invalid Last-Event-ID")
+			return
+		}
+	}
+
+	ctx := c.Request.Context()
+
+	// subscribe before reading, so nothing committed in between is missed
+	wake, unsubscribe := h.events.Subscribe(walletID)
+	defer unsubscribe()
+
+	start, err := h.walletService.GetWalletStreamStart(ctx, walletID)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.Header("Content-Type", sse.ContentType)
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	if !resume {
+		after = start.Sequence
+		writeEvent(c, strconv.FormatInt(after, 10), "balance", start)
+	}
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		for {
+			events, err := h.walletService.ListWalletEvents(ctx, walletID, after, eventBatchSize)
+			if err != nil {
+				if ctx.Err() == nil {
+					writeEvent(c, "", "error", gin.H{"code": "internal_error"})
+				}
+				return
+			}
+
+			for _, e := range events {
+				writeEvent(c, strconv.FormatInt(e.Sequence, 10), "ledger", e)
+				after = e.Sequence
+			}
+
+			if len(events) < eventBatchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-wake:
+		case <-heartbeat.C:
+			c.Writer.WriteString(": heartbeat\n\n")
+			c.Writer.Flush()
+		}
+	}
+}
+
+func writeEvent(c *gin.Context, id string, event string, data any) {
+	sse.Encode(c.Writer, sse.Event{Id: id, Event: event, Data: data})
+	c.Writer.Flush()
+}
@@ -1,18 +1,24 @@
 package api
 
 import (
+    "fmt"
     "net/http"
-	"errors"
     "strconv"
+    "time"
 
     "github.com/gin-gonic/gin"
+    "wallet-service/internal/auth"
+    "wallet-service/internal/ratelimit"
+    "wallet-service/internal/stream"
     "wallet-service/internal/wallet"
-	"github.com/jackc/pgx/v5"
 	"github.com/google/uuid"
 )
 
 type Handler struct{
 	walletService *wallet.Service
+	events        *stream.Hub
+	tokens        *auth.Verifier
+	limiter       *ratelimit.Limiter
 }
 
 type TopUpRequest struct {
@@ -33,6 +39,43 @@ type SpendRequest struct {
     Amount      int64  `json:"amount" binding:"required,gt=0"`
 }
 
+type TransferRequest struct {
+    ReferenceID string `json:"reference_id" binding:"required"`
+    ToWalletID  string `json:"to_wallet_id" binding:"required"`
+    Asset       string `json:"asset" binding:"required"`
+    Amount      int64  `json:"amount" binding:"required,gt=0"`
+}
+
+type SweepRevenueRequest struct {
+    ReferenceID string `json:"reference_id" binding:"required"`
+}
+
+type ReserveHoldRequest struct {
+    ReferenceID      string `json:"reference_id" binding:"required"`
+    Asset            string `json:"asset" binding:"required"`
+    Amount           int64  `json:"amount" binding:"required,gt=0"`
+    ExpiresInSeconds int64  `json:"expires_in_seconds" binding:"omitempty,gt=0,lte=604800"` // optional, at most 7 days
+}
+
+type CaptureHoldRequest struct {
+    ReferenceID string `json:"reference_id" binding:"required"`
+    Amount      *int64 `json:"amount" binding:"omitempty,gt=0"` // optional, defaults to the full hold
+}
+
+type ReverseTransactionRequest struct {
+    ReferenceID string `json:"reference_id" binding:"required"`
+    Amount      *int64 `json:"amount" binding:"omitempty,gt=0"` // optional, defaults to the remaining amount
+}
+
+type RepairReconciliationRequest struct {
+    Confirm bool   `json:"confirm"`
+    Reason  string `json:"reason" binding:"required"`
+}
+
+type AdjustLedgerRequest struct {
+    Reason string `json:"reason" binding:"required"`
+}
+
 type CreateUserRequest struct {
     Name string `json:"name" binding:"required"`
 }
@@ -47,8 +90,15 @@ type CreateWalletRequest struct {
     AssetTypeID int     `json:"asset_type_id" binding:"required"`
 }
 
-func NewHandler(ws *wallet.Service) *Handler{
-	return &Handler{walletService: ws}
+// NewHandler wires the handlers, tokens may be nil to refuse end-user
+// tokens and limiter nil to disable rate limiting.
+func NewHandler(
+	ws *wallet.Service,
+	events *stream.Hub,
+	tokens *auth.Verifier,
+	limiter *ratelimit.Limiter,
+) *Handler{
+	return &Handler{walletService: ws, events: events, tokens: tokens, limiter: limiter}
 }
 
 func (h *Handler) GetBalance(c *gin.Context){
@@ -56,28 +106,39 @@ func (h *Handler) GetBalance(c *gin.Context){
 
     walletId, err := uuid.Parse(walletIDStr)
     if err != nil {
-        c.JSON(http.StatusBadRequest, gin.H{
-            "error": "invalid wallet_id",
-        })
+        badRequest(c, "invalid wallet_id")
+        return
+    }
+
+    if !h.authorizeWallet(c, walletId) {
         return
     }
 	
+	if v, ok := c.GetQuery("as_of"); ok {
+		asOf, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			badRequest(c, "invalid as_of, expected RFC3339 timestamp")
+			return
+		}
+
+		balance, err := h.walletService.GetBalanceAsOf(c.Request.Context(), walletId, asOf)
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, balance)
+		return
+	}
+
 	balance, err := h.walletService.GetBalance(c.Request.Context(), walletId)
 
 	if err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
-            c.JSON(http.StatusNotFound, gin.H{"error": "wallet not found"})
-            return
-        }
-
-        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-        return
+		writeError(c, err)
+		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"wallet_id": walletId,
-		"balance": balance,
-	})
+	c.JSON(http.StatusOK, balance)
 }
 
 func (h *Handler) TopUpWallet(c *gin.Context) {
@@ -85,13 +146,13 @@ func (h *Handler) TopUpWallet(c *gin.Context) {
 
     walletID, err := uuid.Parse(walletIDStr)
     if err != nil {
-        c.JSON(http.StatusBadRequest, gin.H{"error": "invalid wallet id"})
+        badRequest(c, "invalid wallet id")
         return
     }
 
     var req TopUpRequest
     if err := c.ShouldBindJSON(&req); err != nil {
-        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        badRequest(c, err.Error())
         return
     }
 
@@ -106,7 +167,7 @@ func (h *Handler) TopUpWallet(c *gin.Context) {
     )
 
     if err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        writeError(c, err)
         return
     }
 
@@ -120,13 +181,13 @@ func (h *Handler) GrantBonus(c *gin.Context) {
 
     walletID, err := uuid.Parse(walletIDStr)
     if err != nil {
-        c.JSON(http.StatusBadRequest, gin.H{"error": "invalid wallet id"})
+        badRequest(c, "invalid wallet id")
         return
     }
 
     var req BonusRequest
     if err := c.ShouldBindJSON(&req); err != nil {
-        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        badRequest(c, err.Error())
         return
     }
 
@@ -141,7 +202,7 @@ func (h *Handler) GrantBonus(c *gin.Context) {
     )
 
     if err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        writeError(c, err)
         return
     }
 
@@ -155,13 +216,17 @@ func (h *Handler) Spend(c *gin.Context) {
 
     walletID, err := uuid.Parse(walletIDStr)
     if err != nil {
-        c.JSON(http.StatusBadRequest, gin.H{"error": "invalid wallet id"})
+        badRequest(c, "invalid wallet id")
+        return
+    }
+
+    if !h.authorizeWallet(c, walletID) {
         return
     }
 
     var req SpendRequest
     if err := c.ShouldBindJSON(&req); err != nil {
-        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        badRequest(c, err.Error())
         return
     }
 
@@ -176,12 +241,7 @@ func (h *Handler) Spend(c *gin.Context) {
     )
 
     if err != nil {
-        if err.Error() == "insufficient balance" {
-            c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-            return
-        }
-
-        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        writeError(c, err)
         return
     }
 
@@ -190,11 +250,276 @@ func (h *Handler) Spend(c *gin.Context) {
     })
 }
 
+func (h *Handler) Transfer(c *gin.Context) {
+    walletIDStr := c.Param("wallet_id")
+
+    fromWalletID, err := uuid.Parse(walletIDStr)
+    if err != nil {
+        badRequest(c, "invalid wallet id")
+        return
+    }
+
+    if !h.authorizeWallet(c, fromWalletID) {
+        return
+    }
+
+    var req TransferRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        badRequest(c, err.Error())
+        return
+    }
+
+    toWalletID, err := uuid.Parse(req.ToWalletID)
+    if err != nil {
+        badRequest(c, "invalid to_wallet_id")
+        return
+    }
+
+    asset := wallet.AssetCode(req.Asset)
+
+    err = h.walletService.TransferBetweenUsers(
+        c.Request.Context(),
+        req.ReferenceID,
+        fromWalletID,
+        toWalletID,
+        asset,
+        req.Amount,
+    )
+
+    if err != nil {
+        writeError(c, err)
+        return
+    }
+
+    c.JSON(http.StatusOK, gin.H{
+        "status": "transfer successful",
+    })
+}
+
+func (h *Handler) SweepRevenue(c *gin.Context) {
+    asset := wallet.AssetCode(c.Param("code"))
+
+    var req SweepRevenueRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        badRequest(c, err.Error())
+        return
+    }
+
+    amount, err := h.walletService.SweepRevenue(
+        c.Request.Context(),
+        req.ReferenceID,
+        asset,
+    )
+
+    if err != nil {
+        writeError(c, err)
+        return
+    }
+
+    c.JSON(http.StatusOK, gin.H{
+        "status": "revenue swept",
+        "amount": amount,
+    })
+}
+
+func (h *Handler) ReserveHold(c *gin.Context) {
+    walletID, err := uuid.Parse(c.Param("wallet_id"))
+    if err != nil {
+        badRequest(c, "invalid wallet id")
+        return
+    }
+
+    var req ReserveHoldRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        badRequest(c, err.Error())
+        return
+    }
+
+    hold, err := h.walletService.ReserveHold(
+        c.Request.Context(),
+        req.ReferenceID,
+        walletID,
+        wallet.AssetCode(req.Asset),
+        req.Amount,
+        time.Duration(req.ExpiresInSeconds)*time.Second,
+    )
+
+    if err != nil {
+        writeError(c, err)
+        return
+    }
+
+    c.JSON(http.StatusOK, hold)
+}
+
+func (h *Handler) GetHold(c *gin.Context) {
+    holdID, err := uuid.Parse(c.Param("hold_id"))
+    if err != nil {
+        badRequest(c, "invalid hold id")
+        return
+    }
+
+    hold, err := h.walletService.GetHold(c.Request.Context(), holdID)
+    if err != nil {
+        writeError(c, err)
+        return
+    }
+
+    c.JSON(http.StatusOK, hold)
+}
+
+func (h *Handler) CaptureHold(c *gin.Context) {
+    holdID, err := uuid.Parse(c.Param("hold_id"))
+    if err != nil {
+        badRequest(c, "invalid hold id")
+        return
+    }
+
+    var req CaptureHoldRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        badRequest(c, err.Error())
+        return
+    }
+
+    txnID, err := h.walletService.CaptureHold(
+        c.Request.Context(),
+        req.ReferenceID,
+        holdID,
+        req.Amount,
+    )
+
+    if err != nil {
+        writeError(c, err)
+        return
+    }
+
+    c.JSON(http.StatusOK, gin.H{
+        "status":         "captured",
+        "transaction_id": txnID,
+    })
+}
+
+func (h *Handler) VoidHold(c *gin.Context) {
+    holdID, err := uuid.Parse(c.Param("hold_id"))
+    if err != nil {
+        badRequest(c, "invalid hold id")
+        return
+    }
+
+    hold, err := h.walletService.VoidHold(c.Request.Context(), holdID)
+    if err != nil {
+        writeError(c, err)
+        return
+    }
+
+    c.JSON(http.StatusOK, hold)
+}
+
+func (h *Handler) ReverseTransaction(c *gin.Context) {
+    transactionID, err := uuid.Parse(c.Param("id"))
+    if err != nil {
+        badRequest(c, "invalid transaction id")
+        return
+    }
+
+    var req ReverseTransactionRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        badRequest(c, err.Error())
+        return
+    }
+
+    reversalID, err := h.walletService.ReverseTransaction(
+        c.Request.Context(),
+        req.ReferenceID,
+        transactionID,
+        req.Amount,
+    )
+
+    if err != nil {
+        writeError(c, err)
+        return
+    }
+
+    c.JSON(http.StatusOK, gin.H{
+        "status":         "reversed",
+        "transaction_id": reversalID,
+    })
+}
+
+func (h *Handler) GetReconciliation(c *gin.Context) {
+    report, err := h.walletService.Reconcile(c.Request.Context())
+    if err != nil {
+        writeError(c, err)
+        return
+    }
+
+    c.JSON(http.StatusOK, report)
+}
+
+// RepairReconciliation recomputes the cached balance of drifting wallets
+// from the ledger, each correction is recorded with the reason and the
+// calling API key. The body must carry "confirm": true so a stray POST
+// cannot rewrite balances.
+func (h *Handler) RepairReconciliation(c *gin.Context) {
+    var req RepairReconciliationRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        badRequest(c, err.Error())
+        return
+    }
+
+    if !req.Confirm {
+        badRequest(c, "repair requires \"confirm\": true")
+        return
+    }
+
+    report, err := h.walletService.RepairDrift(c.Request.Context(), actor(c), req.Reason)
+    if err != nil {
+        writeError(c, err)
+        return
+    }
+
+    c.JSON(http.StatusOK, report)
+}
+
+// AdjustLedger moves the ledger of one wallet to its cached balance, for
+// drift where the cache turned out to be right.
+func (h *Handler) AdjustLedger(c *gin.Context) {
+    walletID, err := uuid.Parse(c.Param("wallet_id"))
+    if err != nil {
+        badRequest(c, "invalid wallet id")
+        return
+    }
+
+    var req AdjustLedgerRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        badRequest(c, err.Error())
+        return
+    }
+
+    drift, err := h.walletService.AdjustLedgerToBalance(c.Request.Context(), walletID, actor(c), req.Reason)
+    if err != nil {
+        writeError(c, err)
+        return
+    }
+
+    c.JSON(http.StatusOK, drift)
+}
+
+func (h *Handler) GetInvariants(c *gin.Context) {
+    report, err := h.walletService.CheckInvariants(c.Request.Context())
+    if err != nil {
+        writeError(c, err)
+        return
+    }
+
+    c.JSON(http.StatusOK, report)
+}
+
 func (h *Handler) CreateUser(c *gin.Context) {
     var req CreateUserRequest
 
     if err := c.ShouldBindJSON(&req); err != nil {
-        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        badRequest(c, err.Error())
         return
     }
 
@@ -203,7 +528,7 @@ func (h *Handler) CreateUser(c *gin.Context) {
         req.Name,
     )
     if err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        writeError(c, err)
         return
     }
 
@@ -214,7 +539,7 @@ func (h *Handler) CreateAsset(c *gin.Context) {
     var req CreateAssetRequest
 
     if err := c.ShouldBindJSON(&req); err != nil {
-        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        badRequest(c, err.Error())
         return
     }
 
@@ -223,7 +548,7 @@ func (h *Handler) CreateAsset(c *gin.Context) {
         req.Code,
     )
     if err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        writeError(c, err)
         return
     }
 
@@ -234,7 +559,7 @@ func (h *Handler) CreateWallet(c *gin.Context) {
     var req CreateWalletRequest
 
     if err := c.ShouldBindJSON(&req); err != nil {
-        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        badRequest(c, err.Error())
         return
     }
 
@@ -243,7 +568,7 @@ func (h *Handler) CreateWallet(c *gin.Context) {
     if req.UserID != nil {
         parsed, err := uuid.Parse(*req.UserID)
         if err != nil {
-            c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user_id"})
+            badRequest(c, "invalid user_id")
             return
         }
         userUUID = &parsed
@@ -256,58 +581,321 @@ func (h *Handler) CreateWallet(c *gin.Context) {
         req.AssetTypeID,
     )
     if err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        writeError(c, err)
         return
     }
 
     c.JSON(http.StatusOK, gin.H{"wallet_id": id})
 }
 
+// pageResponse is the envelope of cursor paginated listings, next_cursor
+// is null on the last page.
+type pageResponse struct {
+	Data       any     `json:"data"`
+	NextCursor *string `json:"next_cursor"`
+}
+
+func newPageResponse(data any, next *wallet.Cursor) pageResponse {
+	resp := pageResponse{Data: data}
+	if next != nil {
+		encoded := next.Encode()
+		resp.NextCursor = &encoded
+	}
+	return resp
+}
+
 func (h *Handler) GetTransactions(c *gin.Context) {
-	limit, offset := parsePagination(c)
+	limit, cursor, err := parsePagination(c)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	filter, err := parseListFilter(c)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
 
-	data, err := h.walletService.GetTransactions(
+	data, next, err := h.walletService.GetTransactions(
 		c.Request.Context(),
+		filter,
 		limit,
-		offset,
+		cursor,
 	)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		writeError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, data)
+	c.JSON(http.StatusOK, newPageResponse(data, next))
 }
 
 func (h *Handler) GetLedgerEntries(c *gin.Context) {
-	limit, offset := parsePagination(c)
+	limit, cursor, err := parsePagination(c)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
 
-	data, err := h.walletService.GetLedgerEntries(
+	filter, err := parseListFilter(c)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	data, next, err := h.walletService.GetLedgerEntries(
 		c.Request.Context(),
+		filter,
 		limit,
-		offset,
+		cursor,
 	)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		writeError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, data)
+	c.JSON(http.StatusOK, newPageResponse(data, next))
 }
 
-func parsePagination(c *gin.Context) (int, int) {
+func (h *Handler) GetAssetBalances(c *gin.Context) {
+	asset := wallet.AssetCode(c.Param("code"))
+
+	asOf := time.Now().UTC()
+	if v, ok := c.GetQuery("as_of"); ok {
+		var err error
+		asOf, err = time.Parse(time.RFC3339, v)
+		if err != nil {
+			badRequest(c, "invalid as_of, expected RFC3339 timestamp")
+			return
+		}
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "500"))
+	if limit <= 0 || limit > 1000 {
+		limit = 500
+	}
+
+	var after *uuid.UUID
+	if v, ok := c.GetQuery("cursor"); ok {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			writeError(c, wallet.ErrInvalidCursor)
+			return
+		}
+		after = &id
+	}
+
+	balances, err := h.walletService.GetAssetBalancesAsOf(
+		c.Request.Context(),
+		asset,
+		asOf,
+		limit,
+		after,
+	)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	// wallets are paged by id, the cursor is the last wallet id
+	resp := pageResponse{Data: balances}
+	if len(balances) == limit {
+		next := balances[len(balances)-1].WalletID.String()
+		resp.NextCursor = &next
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *Handler) GetStatement(c *gin.Context) {
+    walletID, err := uuid.Parse(c.Param("wallet_id"))
+    if err != nil {
+        badRequest(c, "invalid wallet id")
+        return
+    }
+
+    if !h.authorizeWallet(c, walletID) {
+        return
+    }
+
+    to := time.Now().UTC()
+    if v := c.Query("to"); v != "" {
+        to, err = time.Parse(time.RFC3339, v)
+        if err != nil {
+            badRequest(c, "invalid to, expected RFC3339 timestamp")
+            return
+        }
+    }
+
+    from := to.AddDate(0, 0, -30)
+    if v := c.Query("from"); v != "" {
+        from, err = time.Parse(time.RFC3339, v)
+        if err != nil {
+            badRequest(c, "invalid from, expected RFC3339 timestamp")
+            return
+        }
+    }
+
+    if !from.Before(to) {
+        badRequest(c, "from must be before to")
+        return
+    }
+
+    limit, _ := strconv.Atoi(c.DefaultQuery("limit", "500"))
+    if limit <= 0 || limit > 1000 {
+        limit = 500
+    }
+
+    statement, err := h.walletService.GetStatement(
+        c.Request.Context(),
+        walletID,
+        from,
+        to,
+        limit,
+    )
+    if err != nil {
+        writeError(c, err)
+        return
+    }
+
+    c.JSON(http.StatusOK, statement)
+}
+
+func (h *Handler) GetBalanceHistory(c *gin.Context) {
+	walletID, err := uuid.Parse(c.Param("wallet_id"))
+	if err != nil {
+		badRequest(c, "invalid wallet id")
+		return
+	}
+
+	if !h.authorizeWallet(c, walletID) {
+		return
+	}
+
+	to := time.Now().UTC()
+	if v := c.Query("to"); v != "" {
+		to, err = time.Parse("2006-01-02", v)
+		if err != nil {
+			badRequest(c, "invalid to, expected YYYY-MM-DD date")
+			return
+		}
+	}
+
+	from := to.AddDate(0, 0, -29)
+	if v := c.Query("from"); v != "" {
+		from, err = time.Parse("2006-01-02", v)
+		if err != nil {
+			badRequest(c, "invalid from, expected YYYY-MM-DD date")
+			return
+		}
+	}
+
+	history, err := h.walletService.GetBalanceHistory(
+		c.Request.Context(),
+		walletID,
+		from,
+		to,
+		c.DefaultQuery("interval", "day"),
+	)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, history)
+}
+
+func parsePagination(c *gin.Context) (int, *wallet.Cursor, error) {
 	limitStr := c.DefaultQuery("limit", "50")
-	offsetStr := c.DefaultQuery("offset", "0")
 
 	limit, _ := strconv.Atoi(limitStr)
-	offset, _ := strconv.Atoi(offsetStr)
 
 	if limit <= 0 || limit > 100 {
 		limit = 50
 	}
-	if offset < 0 {
-		offset = 0
+
+	cursorStr := c.Query("cursor")
+	if cursorStr == "" {
+		return limit, nil, nil
+	}
+
+	cursor, err := wallet.DecodeCursor(cursorStr)
+	if err != nil {
+		return 0, nil, err
 	}
 
-	return limit, offset
-}
\ No newline at end of file
+	return limit, &cursor, nil
+}
+
+
+// parseListFilter reads the optional listing filters from the query string,
+// malformed values fail with wallet.ErrInvalidFilter like invalid ones.
+func parseListFilter(c *gin.Context) (wallet.ListFilter, error) {
+	var f wallet.ListFilter
+
+	for _, p := range []struct {
+		name string
+		dst  **uuid.UUID
+	}{
+		{"wallet_id", &f.WalletID},
+		{"user_id", &f.UserID},
+	} {
+		if v, ok := c.GetQuery(p.name); ok {
+			id, err := uuid.Parse(v)
+			if err != nil {
+				return f, fmt.Errorf("%w: invalid %s", wallet.ErrInvalidFilter, p.name)
+			}
+			*p.dst = &id
+		}
+	}
+
+	for _, p := range []struct {
+		name string
+		dst  **string
+	}{
+		{"asset", &f.Asset},
+		{"type", &f.Type},
+		{"status", &f.Status},
+		{"direction", &f.Direction},
+		{"reference_prefix", &f.ReferencePrefix},
+	} {
+		if v, ok := c.GetQuery(p.name); ok {
+			*p.dst = &v
+		}
+	}
+
+	for _, p := range []struct {
+		name string
+		dst  **int64
+	}{
+		{"min_amount", &f.MinAmount},
+		{"max_amount", &f.MaxAmount},
+	} {
+		if v, ok := c.GetQuery(p.name); ok {
+			n, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return f, fmt.Errorf("%w: invalid %s", wallet.ErrInvalidFilter, p.name)
+			}
+			*p.dst = &n
+		}
+	}
+
+	for _, p := range []struct {
+		name string
+		dst  **time.Time
+	}{
+		{"created_from", &f.CreatedFrom},
+		{"created_to", &f.CreatedTo},
+	} {
+		if v, ok := c.GetQuery(p.name); ok {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return f, fmt.Errorf("%w: invalid %s, expected RFC3339 timestamp", wallet.ErrInvalidFilter, p.name)
+			}
+			*p.dst = &t
+		}
+	}
+
+	return f, nil
+}
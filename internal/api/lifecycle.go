@@ -0,0 +1,159 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"wallet-service/internal/wallet"
+)
+
+type FreezeRequest struct {
+	Reason       string `json:"reason" binding:"required"`
+	BlockCredits bool   `json:"block_credits"`
+}
+
+type UnfreezeRequest struct {
+	Reason string `json:"reason" binding:"required"`
+}
+
+type CloseWalletRequest struct {
+	Reason string `json:"reason" binding:"required"`
+	Sweep  bool   `json:"sweep"`
+}
+
+// actor names the API key behind an admin request for the status audit log.
+func actor(c *gin.Context) string {
+	if v, ok := c.Get(apiKeyContextKey); ok {
+		if key, ok := v.(wallet.APIKey); ok {
+			return "api_key:" + key.ID.String() + " (" + key.Name + ")"
+		}
+	}
+	return "unknown"
+}
+
+func (h *Handler) FreezeWallet(c *gin.Context) {
+	walletID, err := uuid.Parse(c.Param("wallet_id"))
+	if err != nil {
+		badRequest(c, "invalid wallet id")
+		return
+	}
+
+	var req FreezeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		badRequest(c, err.Error())
+		return
+	}
+
+	state, err := h.walletService.FreezeWallet(c.Request.Context(), walletID, req.BlockCredits, actor(c), req.Reason)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, state)
+}
+
+func (h *Handler) UnfreezeWallet(c *gin.Context) {
+	walletID, err := uuid.Parse(c.Param("wallet_id"))
+	if err != nil {
+		badRequest(c, "invalid wallet id")
+		return
+	}
+
+	var req UnfreezeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		badRequest(c, err.Error())
+		return
+	}
+
+	state, err := h.walletService.UnfreezeWallet(c.Request.Context(), walletID, actor(c), req.Reason)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, state)
+}
+
+func (h *Handler) CloseWallet(c *gin.Context) {
+	walletID, err := uuid.Parse(c.Param("wallet_id"))
+	if err != nil {
+		badRequest(c, "invalid wallet id")
+		return
+	}
+
+	var req CloseWalletRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		badRequest(c, err.Error())
+		return
+	}
+
+	state, err := h.walletService.CloseWallet(c.Request.Context(), walletID, req.Sweep, actor(c), req.Reason)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, state)
+}
+
+func (h *Handler) FreezeUser(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("user_id"))
+	if err != nil {
+		badRequest(c, "invalid user id")
+		return
+	}
+
+	var req FreezeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		badRequest(c, err.Error())
+		return
+	}
+
+	states, err := h.walletService.FreezeUser(c.Request.Context(), userID, req.BlockCredits, actor(c), req.Reason)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": states})
+}
+
+func (h *Handler) UnfreezeUser(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("user_id"))
+	if err != nil {
+		badRequest(c, "invalid user id")
+		return
+	}
+
+	var req UnfreezeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		badRequest(c, err.Error())
+		return
+	}
+
+	states, err := h.walletService.UnfreezeUser(c.Request.Context(), userID, actor(c), req.Reason)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": states})
+}
+
+func (h *Handler) GetWalletStatusHistory(c *gin.Context) {
+	walletID, err := uuid.Parse(c.Param("wallet_id"))
+	if err != nil {
+		badRequest(c, "invalid wallet id")
+		return
+	}
+
+	changes, err := h.walletService.GetWalletStatusHistory(c.Request.Context(), walletID)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": changes})
+}
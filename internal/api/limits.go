@@ -0,0 +1,104 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"wallet-service/internal/wallet"
+)
+
+type SetVelocityLimitRequest struct {
+	Asset     string     `json:"asset"`
+	WalletID  *uuid.UUID `json:"wallet_id"`
+	TxType    string     `json:"tx_type" binding:"required"`
+	Direction string     `json:"direction" binding:"required"`
+	Window    string     `json:"window" binding:"required"`
+	MaxAmount *int64     `json:"max_amount"`
+	MaxCount  *int64     `json:"max_count"`
+}
+
+func (h *Handler) SetVelocityLimit(c *gin.Context) {
+	var req SetVelocityLimitRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		badRequest(c, err.Error())
+		return
+	}
+
+	limit, err := h.walletService.SetVelocityLimit(c.Request.Context(), wallet.VelocityLimitInput{
+		Asset:     req.Asset,
+		WalletID:  req.WalletID,
+		TxType:    req.TxType,
+		Direction: req.Direction,
+		Window:    req.Window,
+		MaxAmount: req.MaxAmount,
+		MaxCount:  req.MaxCount,
+	})
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, limit)
+}
+
+func (h *Handler) ListVelocityLimits(c *gin.Context) {
+	var asset *string
+	if v := c.Query("asset"); v != "" {
+		asset = &v
+	}
+
+	var walletID *uuid.UUID
+	if v := c.Query("wallet_id"); v != "" {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			badRequest(c, "invalid wallet_id")
+			return
+		}
+		walletID = &id
+	}
+
+	limits, err := h.walletService.ListVelocityLimits(c.Request.Context(), asset, walletID)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": limits})
+}
+
+func (h *Handler) DeleteVelocityLimit(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("limit_id"))
+	if err != nil {
+		badRequest(c, "invalid limit id")
+		return
+	}
+
+	if err := h.walletService.DeleteVelocityLimit(c.Request.Context(), id); err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *Handler) GetWalletLimits(c *gin.Context) {
+	walletID, err := uuid.Parse(c.Param("wallet_id"))
+	if err != nil {
+		badRequest(c, "invalid wallet id")
+		return
+	}
+
+	if !h.authorizeWallet(c, walletID) {
+		return
+	}
+
+	limits, err := h.walletService.GetWalletLimits(c.Request.Context(), walletID)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": limits})
+}
@@ -0,0 +1,49 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type SetOverdraftRequest struct {
+	OverdraftLimit *int64 `json:"overdraft_limit" binding:"required"`
+}
+
+func (h *Handler) SetOverdraftLimit(c *gin.Context) {
+	walletID, err := uuid.Parse(c.Param("wallet_id"))
+	if err != nil {
+		badRequest(c, "invalid wallet id")
+		return
+	}
+
+	var req SetOverdraftRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		badRequest(c, err.Error())
+		return
+	}
+
+	balance, err := h.walletService.SetOverdraftLimit(c.Request.Context(), walletID, *req.OverdraftLimit)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, balance)
+}
+
+func (h *Handler) ListNegativeBalances(c *gin.Context) {
+	var asset *string
+	if v := c.Query("asset"); v != "" {
+		asset = &v
+	}
+
+	balances, err := h.walletService.ListNegativeBalances(c.Request.Context(), asset)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": balances})
+}
@@ -0,0 +1,37 @@
+package api
+
+import (
+	"log"
+	"math"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"wallet-service/internal/wallet"
+)
+
+// rateLimit takes a token for the authenticated client, the wallet of the
+// route, if any, and the route. It writes a 429 with Retry-After and returns
+// false when a bucket is empty. A failing limiter store lets requests
+// through rather than taking the API down with it.
+func (h *Handler) rateLimit(c *gin.Context, client string) bool {
+	if h.limiter == nil {
+		return true
+	}
+
+	route := c.Request.Method + " " + c.FullPath()
+
+	allowed, retryAfter, err := h.limiter.Allow(c.Request.Context(), route, client, c.Param("wallet_id"))
+	if err != nil {
+		log.Printf("rate limiter failed, allowing request: %v", err)
+		return true
+	}
+	if allowed {
+		return true
+	}
+
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	c.Header("Retry-After", strconv.Itoa(max(seconds, 1)))
+	writeError(c, wallet.ErrRateLimited)
+
+	return false
+}
@@ -0,0 +1,83 @@
+package api
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"wallet-service/internal/wallet"
+)
+
+type SupplyChangeRequest struct {
+	ReferenceID string `json:"reference_id" binding:"required"`
+	Amount      int64  `json:"amount" binding:"required"`
+}
+
+type SetMaxSupplyRequest struct {
+	MaxSupply *int64 `json:"max_supply"`
+}
+
+func (h *Handler) MintSupply(c *gin.Context) {
+	h.changeSupply(c, h.walletService.MintSupply)
+}
+
+func (h *Handler) BurnSupply(c *gin.Context) {
+	h.changeSupply(c, h.walletService.BurnSupply)
+}
+
+func (h *Handler) changeSupply(
+	c *gin.Context,
+	change func(ctx context.Context, referenceID string, asset wallet.AssetCode, amount int64) (uuid.UUID, error),
+) {
+	asset := wallet.AssetCode(c.Param("code"))
+
+	var req SupplyChangeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		badRequest(c, err.Error())
+		return
+	}
+
+	txnID, err := change(c.Request.Context(), req.ReferenceID, asset, req.Amount)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	supply, err := h.walletService.GetSupply(c.Request.Context(), asset)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"transaction_id": txnID,
+		"supply":         supply,
+	})
+}
+
+func (h *Handler) SetMaxSupply(c *gin.Context) {
+	var req SetMaxSupplyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		badRequest(c, err.Error())
+		return
+	}
+
+	supply, err := h.walletService.SetMaxSupply(c.Request.Context(), wallet.AssetCode(c.Param("code")), req.MaxSupply)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, supply)
+}
+
+func (h *Handler) GetSupply(c *gin.Context) {
+	supply, err := h.walletService.GetSupply(c.Request.Context(), wallet.AssetCode(c.Param("code")))
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, supply)
+}
@@ -0,0 +1,121 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"wallet-service/internal/wallet"
+)
+
+type CreateWebhookRequest struct {
+	URL        string   `json:"url" binding:"required"`
+	EventTypes []string `json:"event_types"`
+}
+
+func (h *Handler) CreateWebhook(c *gin.Context) {
+	var req CreateWebhookRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		badRequest(c, err.Error())
+		return
+	}
+
+	webhook, err := h.walletService.CreateWebhook(c.Request.Context(), req.URL, req.EventTypes)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, webhook)
+}
+
+func (h *Handler) ListWebhooks(c *gin.Context) {
+	webhooks, err := h.walletService.ListWebhooks(c.Request.Context())
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": webhooks})
+}
+
+func (h *Handler) DeleteWebhook(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("webhook_id"))
+	if err != nil {
+		badRequest(c, "invalid webhook id")
+		return
+	}
+
+	if err := h.walletService.DeleteWebhook(c.Request.Context(), id); err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *Handler) ListWebhookDeliveries(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("webhook_id"))
+	if err != nil {
+		badRequest(c, "invalid webhook id")
+		return
+	}
+
+	var status *string
+	if v := c.Query("status"); v != "" {
+		if v != wallet.DeliveryStatusPending &&
+			v != wallet.DeliveryStatusDelivered &&
+			v != wallet.DeliveryStatusFailed {
+			badRequest(c, "status must be pending, delivered or failed")
+			return
+		}
+		status = &v
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if limit <= 0 || limit > 100 {
+		limit = 50
+	}
+
+	deliveries, err := h.walletService.ListWebhookDeliveries(c.Request.Context(), id, status, limit)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": deliveries})
+}
+
+func (h *Handler) GetWebhookDelivery(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("delivery_id"))
+	if err != nil {
+		badRequest(c, "invalid delivery id")
+		return
+	}
+
+	delivery, err := h.walletService.GetWebhookDelivery(c.Request.Context(), id)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, delivery)
+}
+
+func (h *Handler) ReplayWebhookDelivery(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("delivery_id"))
+	if err != nil {
+		badRequest(c, "invalid delivery id")
+		return
+	}
+
+	delivery, err := h.walletService.ReplayWebhookDelivery(c.Request.Context(), id)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, delivery)
+}
@@ -0,0 +1,21 @@
+package auth
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+type subjectKey struct{}
+
+// WithSubject returns ctx carrying the user an end-user token was issued to.
+func WithSubject(ctx context.Context, userID uuid.UUID) context.Context {
+	return context.WithValue(ctx, subjectKey{}, userID)
+}
+
+// SubjectFrom returns the end-user subject of ctx. ok is false for requests
+// made with an API key, which act for no particular user.
+func SubjectFrom(ctx context.Context) (uuid.UUID, bool) {
+	userID, ok := ctx.Value(subjectKey{}).(uuid.UUID)
+	return userID, ok
+}
@@ -0,0 +1,187 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var ErrInvalidToken = errors.New("invalid token")
+
+// leeway absorbs clock skew between the token issuer and this service.
+const leeway = 30 * time.Second
+
+// Claims are the registered claims of a verified token.
+type Claims struct {
+	Subject   string   `json:"sub"`
+	Issuer    string   `json:"iss"`
+	Audience  audience `json:"aud"`
+	ExpiresAt int64    `json:"exp"`
+	NotBefore int64    `json:"nbf"`
+	IssuedAt  int64    `json:"iat"`
+}
+
+// audience is a JWT aud claim, either a string or an array of strings.
+type audience []string
+
+func (a *audience) UnmarshalJSON(b []byte) error {
+	var one string
+	if err := json.Unmarshal(b, &one); err == nil {
+		*a = audience{one}
+		return nil
+	}
+
+	var many []string
+	if err := json.Unmarshal(b, &many); err != nil {
+		return err
+	}
+	*a = many
+	return nil
+}
+
+func (a audience) contains(s string) bool {
+	for _, v := range a {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Verifier checks JWTs signed with locally configured keys. A token must be
+// signed with the algorithm of a configured key, so an HS256 token is never
+// checked against the RSA public key or the other way round.
+type Verifier struct {
+	hmacSecret []byte
+	rsaKey     *rsa.PublicKey
+
+	// Issuer and Audience, when set, must match the iss and aud claims.
+	Issuer   string
+	Audience string
+}
+
+// NewVerifier accepts HS256 tokens when hmacSecret is set and RS256 tokens
+// when rsaPublicKeyPEM holds a PKIX or PKCS#1 RSA public key.
+func NewVerifier(hmacSecret []byte, rsaPublicKeyPEM []byte) (*Verifier, error) {
+	v := &Verifier{hmacSecret: hmacSecret}
+
+	if len(rsaPublicKeyPEM) > 0 {
+		key, err := parseRSAPublicKey(rsaPublicKeyPEM)
+		if err != nil {
+			return nil, err
+		}
+		v.rsaKey = key
+	}
+
+	if len(v.hmacSecret) == 0 && v.rsaKey == nil {
+		return nil, errors.New("no token verification key configured")
+	}
+
+	return v, nil
+}
+
+func parseRSAPublicKey(b []byte) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, errors.New("rsa public key is not PEM encoded")
+	}
+
+	if key, err := x509.ParsePKCS1PublicKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parse rsa public key: %w", err)
+	}
+	key, ok := parsed.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.New("public key is not an RSA key")
+	}
+
+	return key, nil
+}
+
+// Verify checks the signature and the time, issuer and audience claims of a
+// compact JWT and returns its claims. exp is required.
+func (v *Verifier) Verify(token string) (Claims, error) {
+	var claims Claims
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return claims, fmt.Errorf("%w: malformed", ErrInvalidToken)
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return claims, err
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return claims, fmt.Errorf("%w: malformed signature", ErrInvalidToken)
+	}
+
+	signed := []byte(parts[0] + "." + parts[1])
+
+	switch {
+	case header.Alg == "HS256" && len(v.hmacSecret) > 0:
+		mac := hmac.New(sha256.New, v.hmacSecret)
+		mac.Write(signed)
+		if !hmac.Equal(sig, mac.Sum(nil)) {
+			return claims, fmt.Errorf("%w: bad signature", ErrInvalidToken)
+		}
+	case header.Alg == "RS256" && v.rsaKey != nil:
+		digest := sha256.Sum256(signed)
+		if err := rsa.VerifyPKCS1v15(v.rsaKey, crypto.SHA256, digest[:], sig); err != nil {
+			return claims, fmt.Errorf("%w: bad signature", ErrInvalidToken)
+		}
+	default:
+		return claims, fmt.Errorf("%w: unsupported alg %q", ErrInvalidToken, header.Alg)
+	}
+
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return claims, err
+	}
+
+	now := time.Now()
+	if claims.ExpiresAt == 0 || now.After(time.Unix(claims.ExpiresAt, 0).Add(leeway)) {
+		return claims, fmt.Errorf("%w: expired", ErrInvalidToken)
+	}
+	if claims.NotBefore != 0 && now.Add(leeway).Before(time.Unix(claims.NotBefore, 0)) {
+		return claims, fmt.Errorf("%w: not yet valid", ErrInvalidToken)
+	}
+	if v.Issuer != "" && claims.Issuer != v.Issuer {
+		return claims, fmt.Errorf("%w: wrong issuer", ErrInvalidToken)
+	}
+	if v.Audience != "" && !claims.Audience.contains(v.Audience) {
+		return claims, fmt.Errorf("%w: wrong audience", ErrInvalidToken)
+	}
+	if claims.Subject == "" {
+		return claims, fmt.Errorf("%w: missing subject", ErrInvalidToken)
+	}
+
+	return claims, nil
+}
+
+func decodeSegment(seg string, v any) error {
+	b, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return fmt.Errorf("%w: malformed segment", ErrInvalidToken)
+	}
+	if err := json.Unmarshal(b, v); err != nil {
+		return fmt.Errorf("%w: malformed segment", ErrInvalidToken)
+	}
+	return nil
+}
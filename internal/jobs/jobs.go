@@ -0,0 +1,29 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// Every runs fn every interval until ctx is cancelled. A failing run is
+// logged and retried on the next tick, it never stops the schedule.
+func Every(ctx context.Context, name string, interval time.Duration, fn func(context.Context) error) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		log.Printf("job %s scheduled every %s", name, interval)
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := fn(ctx); err != nil {
+					log.Printf("job %s failed: %v", name, err)
+				}
+			}
+		}
+	}()
+}
@@ -0,0 +1,74 @@
+package outbox
+
+import (
+	"context"
+	"time"
+
+	"wallet-service/internal/wallet"
+)
+
+const (
+	batchSize = 100
+
+	// lease is how long a claimed event is reserved for one dispatcher,
+	// it must outlast publishing a whole batch.
+	lease = 2 * time.Minute
+
+	maxBackoff = 10 * time.Minute
+)
+
+// Store is the outbox side of the wallet repository.
+type Store interface {
+	ClaimLedgerEvents(ctx context.Context, limit int, lease time.Duration) ([]wallet.LedgerEvent, error)
+	MarkLedgerEventDelivered(ctx context.Context, sequence int64) error
+	MarkLedgerEventFailed(ctx context.Context, sequence int64, cause error, retryIn time.Duration) error
+}
+
+// Dispatcher publishes pending outbox events to a sink. An event is marked
+// delivered only after the sink accepted it, so a crash in between publishes
+// it again: delivery is at least once.
+type Dispatcher struct {
+	store Store
+	sink  Sink
+}
+
+func NewDispatcher(store Store, sink Sink) *Dispatcher {
+	return &Dispatcher{store: store, sink: sink}
+}
+
+// Dispatch publishes due events batch by batch until none are left. Failed
+// events are retried with exponential backoff on a later run.
+func (d *Dispatcher) Dispatch(ctx context.Context) error {
+	for {
+		events, err := d.store.ClaimLedgerEvents(ctx, batchSize, lease)
+		if err != nil {
+			return err
+		}
+
+		for _, e := range events {
+			if err := d.sink.Publish(ctx, e); err != nil {
+				err = d.store.MarkLedgerEventFailed(ctx, e.Sequence, err, backoff(e.Attempts))
+				if err != nil {
+					return err
+				}
+				continue
+			}
+
+			if err := d.store.MarkLedgerEventDelivered(ctx, e.Sequence); err != nil {
+				return err
+			}
+		}
+
+		if len(events) < batchSize {
+			return nil
+		}
+	}
+}
+
+// backoff doubles from one second with every failed attempt.
+func backoff(attempts int) time.Duration {
+	if attempts >= 10 {
+		return maxBackoff
+	}
+	return min(time.Second<<attempts, maxBackoff)
+}
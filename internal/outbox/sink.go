@@ -0,0 +1,108 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"wallet-service/internal/wallet"
+)
+
+// Sink publishes ledger events. Publish may be called again for an event it
+// already accepted, so sinks must tolerate duplicates.
+type Sink interface {
+	Publish(ctx context.Context, event wallet.LedgerEvent) error
+}
+
+// WriterSink writes every event as a line of JSON.
+type WriterSink struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func NewWriterSink(w io.Writer) *WriterSink {
+	return &WriterSink{w: w}
+}
+
+// NewFileSink appends events to the file at path, creating it if needed.
+func NewFileSink(path string) (*WriterSink, error) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return NewWriterSink(f), nil
+}
+
+func (s *WriterSink) Publish(ctx context.Context, event wallet.LedgerEvent) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err = s.w.Write(append(line, '\n'))
+	return err
+}
+
+// HTTPSink POSTs every event as JSON to URL. Any status other than 2xx is a
+// failed delivery.
+type HTTPSink struct {
+	URL    string
+	Client *http.Client
+}
+
+func NewHTTPSink(url string) *HTTPSink {
+	return &HTTPSink{
+		URL:    url,
+		Client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (s *HTTPSink) Publish(ctx context.Context, event wallet.LedgerEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Idempotency-Key", event.ID.String())
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("sink responded %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// MultiSink publishes every event to each of its sinks in order and fails
+// on the first sink that fails. The sinks before it see the event again on
+// the retry.
+type MultiSink []Sink
+
+func (m MultiSink) Publish(ctx context.Context, event wallet.LedgerEvent) error {
+	for _, s := range m {
+		if err := s.Publish(ctx, event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
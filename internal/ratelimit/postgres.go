@@ -0,0 +1,64 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// PostgresStore keeps buckets in postgres, so every instance shares them.
+// Buckets are refilled from the database clock.
+type PostgresStore struct {
+	pool *pgxpool.Pool
+}
+
+func NewPostgresStore(pool *pgxpool.Pool) *PostgresStore {
+	return &PostgresStore{pool: pool}
+}
+
+func (s *PostgresStore) Take(ctx context.Context, key string, rule Rule) (bool, time.Duration, error) {
+
+	// refill and take in one statement, the row lock serialises takers
+	var tokens float64
+	err := s.pool.QueryRow(ctx, `
+		INSERT INTO rate_limit_buckets AS b (key, tokens, updated_at)
+		VALUES ($1, $2::float8 - 1, clock_timestamp())
+		ON CONFLICT (key) DO UPDATE
+		SET tokens = LEAST($2::float8, b.tokens + EXTRACT(EPOCH FROM clock_timestamp() - b.updated_at) * $3::float8) - 1,
+		    updated_at = clock_timestamp()
+		WHERE LEAST($2::float8, b.tokens + EXTRACT(EPOCH FROM clock_timestamp() - b.updated_at) * $3::float8) >= 1
+		RETURNING tokens
+	`, key, float64(rule.Tokens), rule.rate()).Scan(&tokens)
+	if err == nil {
+		return true, 0, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return false, 0, err
+	}
+
+	// empty bucket, left untouched
+
+	err = s.pool.QueryRow(ctx, `
+		SELECT LEAST($2::float8, tokens + EXTRACT(EPOCH FROM clock_timestamp() - updated_at) * $3::float8)
+		FROM rate_limit_buckets
+		WHERE key = $1
+	`, key, float64(rule.Tokens), rule.rate()).Scan(&tokens)
+	if err != nil {
+		return false, 0, err
+	}
+
+	return false, time.Duration((1 - tokens) / rule.rate() * float64(time.Second)), nil
+}
+
+// Cleanup deletes buckets idle for longer than idle, they are recreated full
+// on next use. idle should be at least the longest rule period.
+func (s *PostgresStore) Cleanup(ctx context.Context, idle time.Duration) error {
+	_, err := s.pool.Exec(ctx, `
+		DELETE FROM rate_limit_buckets
+		WHERE updated_at < clock_timestamp() - $1 * INTERVAL '1 millisecond'
+	`, idle.Milliseconds())
+	return err
+}
@@ -0,0 +1,146 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Rule is a token bucket holding up to Tokens tokens, refilled at Tokens
+// per Per. Every request takes one token.
+type Rule struct {
+	Tokens int
+	Per    time.Duration
+}
+
+func (r Rule) rate() float64 {
+	return float64(r.Tokens) / r.Per.Seconds()
+}
+
+// ParseRule parses "<tokens>/<duration>", such as "100/1m".
+func ParseRule(s string) (Rule, error) {
+	n, d, ok := strings.Cut(strings.TrimSpace(s), "/")
+	if !ok {
+		return Rule{}, fmt.Errorf("rate limit %q: expected <tokens>/<duration>", s)
+	}
+
+	tokens, err := strconv.Atoi(n)
+	if err != nil || tokens <= 0 {
+		return Rule{}, fmt.Errorf("rate limit %q: tokens must be a positive integer", s)
+	}
+
+	per, err := time.ParseDuration(d)
+	if err != nil || per <= 0 {
+		return Rule{}, fmt.Errorf("rate limit %q: invalid duration", s)
+	}
+
+	return Rule{Tokens: tokens, Per: per}, nil
+}
+
+// Store keeps token buckets. Take removes a token from the bucket at key,
+// created full on first use, or reports how long until one is available.
+type Store interface {
+	Take(ctx context.Context, key string, rule Rule) (bool, time.Duration, error)
+}
+
+// Policy is the rules of a route, nil rules do not limit. Client buckets
+// are per caller and route, wallet buckets per wallet and route, and the
+// route bucket is shared by every caller of the route.
+type Policy struct {
+	Client *Rule
+	Wallet *Rule
+	Route  *Rule
+}
+
+// Limiter applies the policy of each route, Routes overrides Default per
+// rule for the routes it lists, keyed like "POST /wallets/:wallet_id/spend".
+type Limiter struct {
+	store   Store
+	Default Policy
+	Routes  map[string]Policy
+}
+
+func NewLimiter(store Store, def Policy, routes map[string]Policy) *Limiter {
+	return &Limiter{store: store, Default: def, Routes: routes}
+}
+
+func (l *Limiter) policy(route string) Policy {
+	p := l.Default
+
+	if o, ok := l.Routes[route]; ok {
+		if o.Client != nil {
+			p.Client = o.Client
+		}
+		if o.Wallet != nil {
+			p.Wallet = o.Wallet
+		}
+		if o.Route != nil {
+			p.Route = o.Route
+		}
+	}
+
+	return p
+}
+
+// Allow takes a token from every bucket the request falls in. walletID is
+// empty for routes without a wallet. When a bucket is empty it returns
+// false and the longest wait. The shared route bucket is only charged once
+// the client and wallet buckets allowed the request, so a throttled caller
+// cannot drain it for everyone else.
+func (l *Limiter) Allow(
+	ctx context.Context,
+	route string,
+	client string,
+	walletID string,
+) (bool, time.Duration, error) {
+
+	p := l.policy(route)
+
+	type bucket struct {
+		key  string
+		rule *Rule
+	}
+	buckets := []bucket{
+		{"client:" + client + ":" + route, p.Client},
+	}
+	if walletID != "" {
+		buckets = append(buckets, bucket{"wallet:" + walletID + ":" + route, p.Wallet})
+	}
+
+	allowed := true
+	var wait time.Duration
+
+	take := func(b bucket) error {
+		if b.rule == nil {
+			return nil
+		}
+
+		ok, retryAfter, err := l.store.Take(ctx, b.key, *b.rule)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			allowed = false
+			wait = max(wait, retryAfter)
+		}
+		return nil
+	}
+
+	for _, b := range buckets {
+		if err := take(b); err != nil {
+			return false, 0, err
+		}
+	}
+
+	if !allowed {
+		return false, wait, nil
+	}
+
+	if err := take(bucket{"route:" + route, p.Route}); err != nil {
+		return false, 0, err
+	}
+
+	return allowed, wait, nil
+}
@@ -0,0 +1,138 @@
+package stream
+
+import (
+	"context"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"wallet-service/internal/wallet"
+)
+
+// Hub listens for committed ledger events on postgres and wakes the local
+// subscribers of the wallets involved. Every instance runs its own hub, so
+// a transaction posted on one instance reaches the streams of all of them.
+type Hub struct {
+	pool *pgxpool.Pool
+
+	mu   sync.Mutex
+	subs map[uuid.UUID]map[chan struct{}]struct{}
+}
+
+func NewHub(pool *pgxpool.Pool) *Hub {
+	return &Hub{
+		pool: pool,
+		subs: make(map[uuid.UUID]map[chan struct{}]struct{}),
+	}
+}
+
+// Subscribe returns a channel that receives a value whenever the wallet may
+// have new events. Wakeups are coalesced, so readers must fetch everything
+// after the last event they saw. The returned func unsubscribes.
+func (h *Hub) Subscribe(walletID uuid.UUID) (<-chan struct{}, func()) {
+	ch := make(chan struct{}, 1)
+
+	h.mu.Lock()
+	if h.subs[walletID] == nil {
+		h.subs[walletID] = make(map[chan struct{}]struct{})
+	}
+	h.subs[walletID][ch] = struct{}{}
+	h.mu.Unlock()
+
+	return ch, func() {
+		h.mu.Lock()
+		delete(h.subs[walletID], ch)
+		if len(h.subs[walletID]) == 0 {
+			delete(h.subs, walletID)
+		}
+		h.mu.Unlock()
+	}
+}
+
+// Run listens until ctx is cancelled, reconnecting after failures. Every
+// subscriber is woken after a reconnect, since notifications sent while the
+// hub was away are lost.
+func (h *Hub) Run(ctx context.Context) {
+	go func() {
+		for {
+			err := h.listen(ctx)
+			if ctx.Err() != nil {
+				return
+			}
+
+			log.Printf("ledger event listener failed: %v", err)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(time.Second):
+			}
+		}
+	}()
+}
+
+func (h *Hub) listen(ctx context.Context) error {
+	c, err := h.pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+
+	// the connection stays subscribed to the channel, it must not return to
+	// the pool
+	conn := c.Hijack()
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(ctx, "LISTEN "+wallet.LedgerEventsChannel); err != nil {
+		return err
+	}
+
+	h.wakeAll()
+
+	for {
+		n, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+
+		if n.Payload == "*" {
+			h.wakeAll()
+			continue
+		}
+
+		for _, s := range strings.Split(n.Payload, ",") {
+			if id, err := uuid.Parse(s); err == nil {
+				h.wake(id)
+			}
+		}
+	}
+}
+
+func (h *Hub) wake(walletID uuid.UUID) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for ch := range h.subs[walletID] {
+		notify(ch)
+	}
+}
+
+func (h *Hub) wakeAll() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, chans := range h.subs {
+		for ch := range chans {
+			notify(ch)
+		}
+	}
+}
+
+func notify(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,197 @@
+package wallet
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	ScopeAll = "*"
+
+	ScopeReadLedger     = "read:ledger"
+	ScopeWalletTopup    = "wallet:topup"
+	ScopeWalletBonus    = "wallet:bonus"
+	ScopeWalletSpend    = "wallet:spend"
+	ScopeWalletTransfer = "wallet:transfer"
+	ScopeAdminAssets    = "admin:assets"
+	ScopeAdminWallets   = "admin:wallets"
+	ScopeAdminLedger    = "admin:ledger"
+	ScopeAdminWebhooks  = "admin:webhooks"
+	ScopeAdminKeys      = "admin:keys"
+	ScopeAdminLimits    = "admin:limits"
+	ScopeAdminSupply    = "admin:supply"
+)
+
+var apiKeyScopes = map[string]bool{
+	ScopeAll:            true,
+	ScopeReadLedger:     true,
+	ScopeWalletTopup:    true,
+	ScopeWalletBonus:    true,
+	ScopeWalletSpend:    true,
+	ScopeWalletTransfer: true,
+	ScopeAdminAssets:    true,
+	ScopeAdminWallets:   true,
+	ScopeAdminLedger:    true,
+	ScopeAdminWebhooks:  true,
+	ScopeAdminKeys:      true,
+	ScopeAdminLimits:    true,
+	ScopeAdminSupply:    true,
+}
+
+// APIKeyPrefix starts every API key, telling keys apart from end-user tokens.
+const APIKeyPrefix = "wsk_"
+
+type APIKey struct {
+	ID         uuid.UUID  `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+
+	// Key is only set when the key is issued.
+	Key string `json:"key,omitempty"`
+}
+
+// HasScope reports whether the key grants scope.
+func (k APIKey) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope || s == ScopeAll {
+			return true
+		}
+	}
+	return false
+}
+
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// IssueAPIKey creates a key with the given scopes and returns it with the
+// plaintext key, which is not stored and cannot be retrieved again.
+func (r *Repository) IssueAPIKey(ctx context.Context, name string, scopes []string) (APIKey, error) {
+
+	if strings.TrimSpace(name) == "" {
+		return APIKey{}, fmt.Errorf("%w: name is required", ErrInvalidAPIKey)
+	}
+	if len(scopes) == 0 {
+		return APIKey{}, fmt.Errorf("%w: at least one scope is required", ErrInvalidAPIKey)
+	}
+	for _, s := range scopes {
+		if !apiKeyScopes[s] {
+			return APIKey{}, fmt.Errorf("%w: unknown scope %q", ErrInvalidAPIKey, s)
+		}
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return APIKey{}, err
+	}
+
+	k := APIKey{
+		ID:     uuid.New(),
+		Name:   name,
+		Scopes: scopes,
+		Key:    APIKeyPrefix + hex.EncodeToString(secret),
+	}
+	k.Prefix = k.Key[:len(APIKeyPrefix)+8]
+
+	err := r.pool.QueryRow(ctx, `
+		INSERT INTO api_keys (id, name, prefix, key_hash, scopes)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING created_at
+	`, k.ID, k.Name, k.Prefix, hashAPIKey(k.Key), k.Scopes).Scan(&k.CreatedAt)
+
+	return k, err
+}
+
+const apiKeyColumns = `id, name, prefix, scopes, created_at, last_used_at, revoked_at`
+
+func scanAPIKey(row pgx.Row) (APIKey, error) {
+	var k APIKey
+
+	err := row.Scan(&k.ID, &k.Name, &k.Prefix, &k.Scopes, &k.CreatedAt, &k.LastUsedAt, &k.RevokedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return k, ErrAPIKeyNotFound
+	}
+
+	return k, err
+}
+
+// AuthenticateAPIKey returns the unrevoked key matching the plaintext key,
+// or ErrUnauthenticated.
+func (r *Repository) AuthenticateAPIKey(ctx context.Context, key string) (APIKey, error) {
+
+	if !strings.HasPrefix(key, APIKeyPrefix) {
+		return APIKey{}, ErrUnauthenticated
+	}
+
+	k, err := scanAPIKey(r.pool.QueryRow(ctx, `
+		SELECT `+apiKeyColumns+`
+		FROM api_keys
+		WHERE key_hash = $1 AND revoked_at IS NULL
+	`, hashAPIKey(key)))
+	if errors.Is(err, ErrAPIKeyNotFound) {
+		return k, ErrUnauthenticated
+	}
+	if err != nil {
+		return k, err
+	}
+
+	// last_used_at is only kept to the minute, so busy keys are not written
+	// on every request
+	_, err = r.pool.Exec(ctx, `
+		UPDATE api_keys SET last_used_at = NOW()
+		WHERE id = $1
+		  AND (last_used_at IS NULL OR last_used_at < NOW() - INTERVAL '1 minute')
+	`, k.ID)
+
+	return k, err
+}
+
+func (r *Repository) ListAPIKeys(ctx context.Context) ([]APIKey, error) {
+
+	rows, err := r.pool.Query(ctx, `
+		SELECT `+apiKeyColumns+`
+		FROM api_keys
+		ORDER BY created_at, id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []APIKey{}
+
+	for rows.Next() {
+		k, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+
+	return keys, rows.Err()
+}
+
+// RevokeAPIKey revokes a key, revoking it again is a no-op.
+func (r *Repository) RevokeAPIKey(ctx context.Context, id uuid.UUID) (APIKey, error) {
+	return scanAPIKey(r.pool.QueryRow(ctx, `
+		UPDATE api_keys
+		SET revoked_at = COALESCE(revoked_at, NOW())
+		WHERE id = $1
+		RETURNING `+apiKeyColumns,
+		id,
+	))
+}
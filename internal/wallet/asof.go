@@ -0,0 +1,128 @@
+package wallet
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+type HistoricalBalance struct {
+	WalletID uuid.UUID `json:"wallet_id"`
+	Asset    string    `json:"asset"`
+	AsOf     time.Time `json:"as_of"`
+	Balance  int64     `json:"balance"`
+}
+
+// GetWalletBalanceAsOf derives a wallet balance at asOf from the ledger,
+// entries created at exactly asOf are included. The latest daily snapshot
+// before asOf, when there is one, stands in for the entries it covers.
+func (r *Repository) GetWalletBalanceAsOf(
+	ctx context.Context,
+	walletID uuid.UUID,
+	asOf time.Time,
+) (HistoricalBalance, error) {
+
+	b := HistoricalBalance{WalletID: walletID, AsOf: asOf}
+
+	asset, err := r.GetWalletAssetCode(ctx, walletID)
+	if err != nil {
+		return b, err
+	}
+	b.Asset = asset
+
+	// start from the latest end of day snapshot at or before asOf, so only
+	// the entries after it are summed
+
+	var snapshot int64
+	since := time.Time{}
+
+	err = r.pool.QueryRow(ctx, `
+		SELECT day, balance
+		FROM wallet_balance_snapshots
+		WHERE wallet_id = $1 AND day < ($2::timestamp)::date
+		ORDER BY day DESC
+		LIMIT 1
+	`, walletID, asOf).Scan(&since, &snapshot)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return b, err
+	}
+	if err == nil {
+		since = utcDay(since).AddDate(0, 0, 1)
+	}
+
+	err = r.pool.QueryRow(ctx, `
+		SELECT COALESCE(SUM(
+			CASE WHEN direction = 'credit' THEN amount ELSE -amount END
+		), 0)
+		FROM ledger_entries
+		WHERE wallet_id = $1 AND created_at >= $2 AND created_at <= $3
+	`, walletID, since, asOf).Scan(&b.Balance)
+
+	b.Balance += snapshot
+
+	return b, err
+}
+
+// ListAssetBalancesAsOf derives the balance at asOf of every wallet of an
+// asset that existed by then, ordered by wallet id and starting after
+// afterWalletID when it is set.
+func (r *Repository) ListAssetBalancesAsOf(
+	ctx context.Context,
+	assetCode string,
+	asOf time.Time,
+	limit int,
+	afterWalletID *uuid.UUID,
+) ([]HistoricalBalance, error) {
+
+	var assetTypeID int
+	err := r.pool.QueryRow(ctx,
+		`SELECT id FROM assets WHERE code = $1`,
+		assetCode,
+	).Scan(&assetTypeID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrAssetNotFound
+		}
+		return nil, err
+	}
+
+	after := uuid.Nil
+	if afterWalletID != nil {
+		after = *afterWalletID
+	}
+
+	rows, err := r.pool.Query(ctx, `
+		SELECT w.id,
+		       COALESCE(SUM(
+		           CASE WHEN e.direction = 'credit' THEN e.amount ELSE -e.amount END
+		       ), 0)
+		FROM wallets w
+		LEFT JOIN ledger_entries e
+		       ON e.wallet_id = w.id AND e.created_at <= $2
+		WHERE w.asset_type_id = $1
+		  AND w.created_at <= $2
+		  AND w.id > $3
+		GROUP BY w.id
+		ORDER BY w.id
+		LIMIT $4
+	`, assetTypeID, asOf, after, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	balances := []HistoricalBalance{}
+
+	for rows.Next() {
+		b := HistoricalBalance{Asset: assetCode, AsOf: asOf}
+		if err := rows.Scan(&b.WalletID, &b.Balance); err != nil {
+			return nil, err
+		}
+		balances = append(balances, b)
+	}
+
+	return balances, rows.Err()
+}
@@ -0,0 +1,171 @@
+package wallet
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrWalletNotFound      = errors.New("wallet not found")
+	ErrUserNotFound        = errors.New("user not found")
+	ErrAssetNotFound       = errors.New("asset not found")
+	ErrUnsupportedAsset    = errors.New("unsupported asset type")
+	ErrAssetMismatch       = errors.New("wallet asset mismatch")
+	ErrInsufficientFunds   = errors.New("insufficient balance")
+	ErrInvalidAmount       = errors.New("amount must be positive")
+	ErrInvalidPosting      = errors.New("invalid journal posting")
+	ErrUnbalancedJournal   = errors.New("journal debits do not equal credits")
+	ErrDuplicateReference  = errors.New("duplicate reference_id")
+	ErrDuplicateAsset      = errors.New("asset already exists")
+	ErrDuplicateWallet     = errors.New("user already has a wallet for this asset")
+	ErrIdempotencyConflict = errors.New("reference_id already used with different parameters")
+	ErrWalletFrozen        = errors.New("wallet is frozen")
+	ErrWalletClosed        = errors.New("wallet is closed")
+	ErrWalletNotEmpty      = errors.New("wallet has a balance or active holds")
+	ErrInvalidStatusChange = errors.New("invalid wallet status change")
+	ErrSameWallet          = errors.New("cannot transfer to the same wallet")
+	ErrNotUserWallet       = errors.New("wallet is not a user wallet")
+	ErrNothingToSweep      = errors.New("revenue wallet is empty")
+	ErrRetriesExhausted    = errors.New("operation failed after retries")
+	ErrInvalidCursor       = errors.New("invalid cursor")
+	ErrInvalidFilter       = errors.New("invalid filter")
+	ErrNotRepairable       = errors.New("wallet drift cannot be repaired")
+	ErrInvalidRepair       = errors.New("invalid repair request")
+
+	ErrTransactionNotFound        = errors.New("transaction not found")
+	ErrNotReversible              = errors.New("transaction cannot be reversed")
+	ErrReversalExceedsRemaining   = errors.New("reversal amount exceeds remaining amount")
+	ErrPartialReversalUnsupported = errors.New("partial reversal is only supported for two legged transactions")
+
+	ErrHoldNotFound       = errors.New("hold not found")
+	ErrHoldNotActive      = errors.New("hold is not active")
+	ErrHoldExpired        = errors.New("hold has expired")
+	ErrCaptureExceedsHold = errors.New("capture amount exceeds hold amount")
+	ErrInvalidHoldTTL     = errors.New("invalid hold ttl")
+
+	ErrUnsupportedInterval = errors.New("unsupported balance history interval")
+	ErrInvalidHistoryRange = errors.New("invalid balance history range")
+
+	ErrInvalidWebhook          = errors.New("invalid webhook")
+	ErrWebhookNotFound         = errors.New("webhook not found")
+	ErrWebhookDeliveryNotFound = errors.New("webhook delivery not found")
+
+	ErrUnauthenticated = errors.New("missing or invalid credentials")
+	ErrForbidden       = errors.New("credentials lack the required scope")
+	ErrInvalidAPIKey   = errors.New("invalid api key")
+	ErrAPIKeyNotFound  = errors.New("api key not found")
+	ErrWalletForbidden = errors.New("wallet belongs to another user")
+	ErrRateLimited     = errors.New("rate limit exceeded")
+
+	ErrInvalidLimit  = errors.New("invalid velocity limit")
+	ErrLimitNotFound = errors.New("velocity limit not found")
+	ErrLimitExceeded = errors.New("velocity limit exceeded")
+
+	ErrInvalidOverdraft = errors.New("invalid overdraft limit")
+	ErrOverdraftInUse   = errors.New("wallet balance is below the overdraft limit")
+
+	ErrSupplyCapExceeded = errors.New("asset max supply exceeded")
+	ErrInvalidMaxSupply  = errors.New("invalid max supply")
+)
+
+// AssetMismatchError is returned when a wallet holds a different asset than
+// the one requested. It matches ErrAssetMismatch with errors.Is.
+type AssetMismatchError struct {
+	WalletID       uuid.UUID
+	WalletAsset    AssetCode
+	RequestedAsset AssetCode
+}
+
+func (e *AssetMismatchError) Error() string {
+	return fmt.Sprintf(
+		"wallet asset mismatch: wallet=%s request=%s",
+		e.WalletAsset,
+		e.RequestedAsset,
+	)
+}
+
+func (e *AssetMismatchError) Is(target error) bool {
+	return target == ErrAssetMismatch
+}
+
+// InsufficientFundsError is returned when a debit exceeds the wallet
+// balance and overdraft limit. It matches ErrInsufficientFunds with
+// errors.Is.
+type InsufficientFundsError struct {
+	WalletID       uuid.UUID
+	Balance        int64
+	OverdraftLimit int64
+	Requested      int64
+}
+
+func (e *InsufficientFundsError) Error() string {
+	if e.OverdraftLimit > 0 {
+		return fmt.Sprintf(
+			"insufficient balance: wallet=%s balance=%d overdraft_limit=%d requested=%d",
+			e.WalletID,
+			e.Balance,
+			e.OverdraftLimit,
+			e.Requested,
+		)
+	}
+	return fmt.Sprintf(
+		"insufficient balance: wallet=%s balance=%d requested=%d",
+		e.WalletID,
+		e.Balance,
+		e.Requested,
+	)
+}
+
+func (e *InsufficientFundsError) Is(target error) bool {
+	return target == ErrInsufficientFunds
+}
+
+// LimitExceededError is returned when a journal would take a wallet past a
+// velocity limit. Limit holds the usage before the journal. It matches
+// ErrLimitExceeded with errors.Is.
+type LimitExceededError struct {
+	WalletID  uuid.UUID
+	Limit     LimitUsage
+	Requested int64
+}
+
+func (e *LimitExceededError) Error() string {
+	return fmt.Sprintf(
+		"velocity limit exceeded: wallet=%s %s %s per %s requested=%d",
+		e.WalletID,
+		e.Limit.TxType,
+		e.Limit.Direction,
+		e.Limit.Window,
+		e.Requested,
+	)
+}
+
+func (e *LimitExceededError) Is(target error) bool {
+	return target == ErrLimitExceeded
+}
+
+// SupplyCapError is returned when a mint would take the issued supply of an
+// asset past its max supply. It matches ErrSupplyCapExceeded with
+// errors.Is.
+type SupplyCapError struct {
+	Asset     AssetCode
+	MaxSupply int64
+	Issued    int64
+	Requested int64
+}
+
+func (e *SupplyCapError) Error() string {
+	return fmt.Sprintf(
+		"asset max supply exceeded: asset=%s max_supply=%d issued=%d requested=%d",
+		e.Asset,
+		e.MaxSupply,
+		e.Issued,
+		e.Requested,
+	)
+}
+
+func (e *SupplyCapError) Is(target error) bool {
+	return target == ErrSupplyCapExceeded
+}
@@ -0,0 +1,218 @@
+package wallet
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ListFilter narrows transaction and ledger entry listings, nil fields are
+// ignored. For transactions the entry level fields (wallet, user, asset,
+// direction, amount) match when at least one leg matches all of them.
+type ListFilter struct {
+	WalletID        *uuid.UUID
+	UserID          *uuid.UUID
+	Asset           *string
+	Type            *string
+	Status          *string
+	Direction       *string
+	MinAmount       *int64
+	MaxAmount       *int64
+	CreatedFrom     *time.Time
+	CreatedTo       *time.Time
+	ReferencePrefix *string
+}
+
+var transactionStatuses = map[string]bool{
+	StatusCompleted:         true,
+	StatusReversed:          true,
+	StatusPartiallyReversed: true,
+}
+
+// transactionTypes are the types a transaction can have, purchase is the
+// type of the seeded opening transactions.
+var transactionTypes = map[string]bool{
+	TxTypeTopup:      true,
+	TxTypeBonus:      true,
+	TxTypeSpend:      true,
+	TxTypeTransfer:   true,
+	TxTypeSweep:      true,
+	TxTypeReversal:   true,
+	TxTypeCapture:    true,
+	TxTypeAdjustment: true,
+	TxTypeClosure:    true,
+	TxTypeMint:       true,
+	TxTypeBurn:       true,
+	"purchase":       true,
+}
+
+// normalize uppercases the asset code, which is how assets are stored, so
+// the existence check and the query see the same code.
+func (f *ListFilter) normalize() {
+	if f.Asset != nil {
+		asset := strings.ToUpper(*f.Asset)
+		f.Asset = &asset
+	}
+}
+
+func (f ListFilter) Validate() error {
+	if f.Type != nil && !transactionTypes[*f.Type] {
+		return fmt.Errorf("%w: unknown type %q", ErrInvalidFilter, *f.Type)
+	}
+	if f.Direction != nil && *f.Direction != DirectionDebit && *f.Direction != DirectionCredit {
+		return fmt.Errorf("%w: direction must be debit or credit", ErrInvalidFilter)
+	}
+	if f.Status != nil && !transactionStatuses[*f.Status] {
+		return fmt.Errorf("%w: unknown status %q", ErrInvalidFilter, *f.Status)
+	}
+	if f.MinAmount != nil && *f.MinAmount <= 0 {
+		return fmt.Errorf("%w: min_amount must be positive", ErrInvalidFilter)
+	}
+	if f.MaxAmount != nil && *f.MaxAmount <= 0 {
+		return fmt.Errorf("%w: max_amount must be positive", ErrInvalidFilter)
+	}
+	if f.MinAmount != nil && f.MaxAmount != nil && *f.MinAmount > *f.MaxAmount {
+		return fmt.Errorf("%w: min_amount is greater than max_amount", ErrInvalidFilter)
+	}
+	if f.CreatedFrom != nil && f.CreatedTo != nil && !f.CreatedFrom.Before(*f.CreatedTo) {
+		return fmt.Errorf("%w: created_from must be before created_to", ErrInvalidFilter)
+	}
+	if f.ReferencePrefix != nil && *f.ReferencePrefix == "" {
+		return fmt.Errorf("%w: reference_prefix is empty", ErrInvalidFilter)
+	}
+	return nil
+}
+
+// checkFilterAsset rejects an asset filter naming an asset that does not
+// exist, which would otherwise quietly match nothing.
+func (r *Repository) checkFilterAsset(ctx context.Context, f ListFilter) error {
+	if f.Asset == nil {
+		return nil
+	}
+
+	var exists bool
+	err := r.pool.QueryRow(ctx,
+		`SELECT EXISTS (SELECT 1 FROM assets WHERE code = $1)`,
+		*f.Asset,
+	).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("%w: unknown asset %q", ErrInvalidFilter, *f.Asset)
+	}
+	return nil
+}
+
+// whereBuilder collects AND-ed conditions written with ? placeholders and
+// numbers them into postgres $n parameters.
+type whereBuilder struct {
+	conds []string
+	args  []any
+}
+
+func (b *whereBuilder) add(cond string, args ...any) {
+	for _, arg := range args {
+		b.args = append(b.args, arg)
+		cond = strings.Replace(cond, "?", "$"+strconv.Itoa(len(b.args)), 1)
+	}
+	b.conds = append(b.conds, cond)
+}
+
+// arg appends a bare parameter, such as a LIMIT, and returns its placeholder.
+func (b *whereBuilder) arg(v any) string {
+	b.args = append(b.args, v)
+	return "$" + strconv.Itoa(len(b.args))
+}
+
+func (b *whereBuilder) sql() string {
+	if len(b.conds) == 0 {
+		return ""
+	}
+	return "WHERE " + strings.Join(b.conds, " AND ")
+}
+
+// addEntryConditions adds the entry level filters against the ledger entry
+// alias e, joined to its wallet as w and asset as a.
+func (b *whereBuilder) addEntryConditions(f ListFilter) {
+	if f.WalletID != nil {
+		b.add("e.wallet_id = ?", *f.WalletID)
+	}
+	if f.UserID != nil {
+		b.add("w.user_id = ?", *f.UserID)
+	}
+	if f.Asset != nil {
+		b.add("a.code = ?", *f.Asset)
+	}
+	if f.Direction != nil {
+		b.add("e.direction = ?", *f.Direction)
+	}
+	if f.MinAmount != nil {
+		b.add("e.amount >= ?", *f.MinAmount)
+	}
+	if f.MaxAmount != nil {
+		b.add("e.amount <= ?", *f.MaxAmount)
+	}
+}
+
+// addTransactionConditions adds the transaction level filters against the
+// transaction alias t.
+func (b *whereBuilder) addTransactionConditions(f ListFilter) {
+	if f.Type != nil {
+		b.add("t.type = ?", *f.Type)
+	}
+	if f.Status != nil {
+		b.add("t.status = ?", *f.Status)
+	}
+	if f.ReferencePrefix != nil {
+		b.add(`t.reference_id LIKE ? ESCAPE '\'`, escapeLike(*f.ReferencePrefix)+"%")
+	}
+}
+
+// addTransactionFilter adds every filter of f to a transaction listing,
+// entry level filters become an EXISTS over the transaction's legs.
+func (b *whereBuilder) addTransactionFilter(f ListFilter) {
+	b.addTransactionConditions(f)
+	b.addCreatedRange("t", f)
+
+	if f.hasEntryConditions() {
+		legs := &whereBuilder{args: b.args}
+		legs.addEntryConditions(f)
+		b.args = legs.args
+		b.conds = append(b.conds, `EXISTS (
+			SELECT 1
+			FROM ledger_entries e
+			JOIN wallets w ON w.id = e.wallet_id
+			JOIN assets a ON a.id = w.asset_type_id
+			WHERE e.transaction_id = t.id AND `+strings.Join(legs.conds, " AND ")+`
+		)`)
+	}
+}
+
+func (b *whereBuilder) addCreatedRange(alias string, f ListFilter) {
+	if f.CreatedFrom != nil {
+		b.add(alias+".created_at >= ?", f.CreatedFrom.UTC())
+	}
+	if f.CreatedTo != nil {
+		b.add(alias+".created_at < ?", f.CreatedTo.UTC())
+	}
+}
+
+func (b *whereBuilder) addCursor(alias string, cursor *Cursor) {
+	if cursor != nil {
+		b.add("("+alias+".created_at, "+alias+".id) < (?, ?)", cursor.CreatedAt, cursor.ID)
+	}
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+func (f ListFilter) hasEntryConditions() bool {
+	return f.WalletID != nil || f.UserID != nil || f.Asset != nil ||
+		f.Direction != nil || f.MinAmount != nil || f.MaxAmount != nil
+}
@@ -0,0 +1,354 @@
+package wallet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	HoldStatusActive   = "active"
+	HoldStatusCaptured = "captured"
+	HoldStatusVoided   = "voided"
+	HoldStatusExpired  = "expired"
+)
+
+// querier is satisfied by both the pool and a transaction.
+type querier interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+}
+
+// heldAmount sums the active, unexpired holds of a wallet.
+func heldAmount(ctx context.Context, q querier, walletID uuid.UUID) (int64, error) {
+	var held int64
+
+	err := q.QueryRow(ctx, `
+		SELECT COALESCE(SUM(amount), 0)
+		FROM holds
+		WHERE wallet_id = $1
+		  AND status = 'active'
+		  AND expires_at > NOW()
+	`, walletID).Scan(&held)
+
+	return held, err
+}
+
+func (r *Repository) GetWalletBalances(
+	ctx context.Context,
+	walletID uuid.UUID,
+) (Balance, error) {
+
+	b := Balance{WalletID: walletID}
+
+	err := r.pool.QueryRow(ctx,
+		`SELECT balance, overdraft_limit, status FROM wallets WHERE id = $1`,
+		walletID,
+	).Scan(&b.Balance, &b.OverdraftLimit, &b.Status)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return b, fmt.Errorf("wallet with id %s not found: %w", walletID, ErrWalletNotFound)
+		}
+		return b, err
+	}
+
+	b.Held, err = heldAmount(ctx, r.pool, walletID)
+	if err != nil {
+		return b, err
+	}
+
+	b.Available = b.Balance - b.Held
+
+	return b, nil
+}
+
+// ReserveHold reserves amount on a wallet until expiresAt. It fails with
+// ErrInsufficientFunds when the available balance is too low.
+func (r *Repository) ReserveHold(
+	ctx context.Context,
+	referenceID string,
+	walletID uuid.UUID,
+	amount int64,
+	expiresAt time.Time,
+) (Hold, error) {
+
+	if amount <= 0 {
+		return Hold{}, ErrInvalidAmount
+	}
+
+	tx, err := r.pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return Hold{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	// check if hold already reserved
+
+	existing, err := scanHold(tx.QueryRow(ctx,
+		holdSelect+` WHERE reference_id = $1`,
+		referenceID,
+	))
+	if err == nil {
+		if existing.WalletID != walletID || existing.Amount != amount {
+			return Hold{}, ErrIdempotencyConflict
+		}
+		return existing, tx.Commit(ctx)
+	}
+	if !errors.Is(err, ErrHoldNotFound) {
+		return Hold{}, err
+	}
+
+	// lock the wallet so concurrent reservations see each other
+
+	wallets, err := lockWallets(ctx, tx, []uuid.UUID{walletID})
+	if err != nil {
+		return Hold{}, err
+	}
+
+	w := wallets[walletID]
+	if err := w.checkStatus(walletID, true, false); err != nil {
+		return Hold{}, err
+	}
+	if !w.covers(amount) {
+		return Hold{}, w.insufficientFunds(walletID, amount)
+	}
+
+	hold, err := scanHold(tx.QueryRow(ctx, `
+		INSERT INTO holds (id, reference_id, wallet_id, amount, status, expires_at)
+		VALUES ($1, $2, $3, $4, 'active', $5)
+		RETURNING `+holdColumns,
+		uuid.New(),
+		referenceID,
+		walletID,
+		amount,
+		expiresAt,
+	))
+	if err != nil {
+		return Hold{}, err
+	}
+
+	return hold, tx.Commit(ctx)
+}
+
+// CaptureHold posts the real ledger entries for a hold, moving the captured
+// amount to toWalletID. A nil amount captures the full hold, the uncaptured
+// remainder is released.
+func (r *Repository) CaptureHold(
+	ctx context.Context,
+	referenceID string,
+	holdID uuid.UUID,
+	amount *int64,
+	toWalletID uuid.UUID,
+) (uuid.UUID, error) {
+
+	if amount != nil && *amount <= 0 {
+		return uuid.Nil, ErrInvalidAmount
+	}
+
+	tx, err := r.pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return uuid.Nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	hold, err := lockHold(ctx, tx, holdID)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	captureAmount := hold.Amount
+	if amount != nil {
+		captureAmount = *amount
+	}
+
+	// a replayed capture returns the original transaction
+	if hold.Status == HoldStatusCaptured {
+		var existingRef string
+		err := tx.QueryRow(ctx,
+			`SELECT reference_id FROM transactions WHERE id = $1`,
+			hold.CaptureTransactionID,
+		).Scan(&existingRef)
+		if err != nil {
+			return uuid.Nil, err
+		}
+		if existingRef != referenceID || hold.CapturedAmount != captureAmount {
+			return uuid.Nil, ErrIdempotencyConflict
+		}
+		return *hold.CaptureTransactionID, tx.Commit(ctx)
+	}
+
+	if err := checkHoldActive(hold); err != nil {
+		if errors.Is(err, ErrHoldExpired) {
+			if err := expireHold(ctx, tx, holdID); err != nil {
+				return uuid.Nil, err
+			}
+		}
+		return uuid.Nil, err
+	}
+
+	if captureAmount > hold.Amount {
+		return uuid.Nil, fmt.Errorf(
+			"requested=%d held=%d: %w",
+			captureAmount,
+			hold.Amount,
+			ErrCaptureExceedsHold,
+		)
+	}
+
+	// the hold is not captured yet, so a transaction that already has the
+	// reference belongs to something else and must not be replayed as this
+	// capture
+	var referenceTaken bool
+	err = tx.QueryRow(ctx,
+		`SELECT EXISTS (SELECT 1 FROM transactions WHERE reference_id = $1)`,
+		referenceID,
+	).Scan(&referenceTaken)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if referenceTaken {
+		return uuid.Nil, ErrIdempotencyConflict
+	}
+
+	// release the hold first so its funds are available to the journal
+
+	_, err = tx.Exec(ctx, `
+		UPDATE holds
+		SET status = 'captured', captured_amount = $1, updated_at = NOW()
+		WHERE id = $2
+	`, captureAmount, holdID)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	txnID, err := postJournal(ctx, tx, journal{
+		referenceID: referenceID,
+		txType:      TxTypeCapture,
+		legs: []Posting{
+			{WalletID: hold.WalletID, Direction: DirectionDebit, Amount: captureAmount},
+			{WalletID: toWalletID, Direction: DirectionCredit, Amount: captureAmount},
+		},
+	})
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	_, err = tx.Exec(ctx,
+		`UPDATE holds SET capture_transaction_id = $1 WHERE id = $2`,
+		txnID,
+		holdID,
+	)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	return txnID, tx.Commit(ctx)
+}
+
+// VoidHold releases an active hold without posting ledger entries. Voiding
+// an already voided hold is a no-op.
+func (r *Repository) VoidHold(ctx context.Context, holdID uuid.UUID) (Hold, error) {
+
+	tx, err := r.pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return Hold{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	hold, err := lockHold(ctx, tx, holdID)
+	if err != nil {
+		return Hold{}, err
+	}
+
+	if hold.Status == HoldStatusVoided {
+		return hold, tx.Commit(ctx)
+	}
+
+	if err := checkHoldActive(hold); err != nil {
+		if errors.Is(err, ErrHoldExpired) {
+			if err := expireHold(ctx, tx, holdID); err != nil {
+				return Hold{}, err
+			}
+		}
+		return Hold{}, err
+	}
+
+	hold, err = scanHold(tx.QueryRow(ctx, `
+		UPDATE holds
+		SET status = 'voided', updated_at = NOW()
+		WHERE id = $1
+		RETURNING `+holdColumns,
+		holdID,
+	))
+	if err != nil {
+		return Hold{}, err
+	}
+
+	return hold, tx.Commit(ctx)
+}
+
+func (r *Repository) GetHold(ctx context.Context, holdID uuid.UUID) (Hold, error) {
+	return scanHold(r.pool.QueryRow(ctx, holdSelect+` WHERE id = $1`, holdID))
+}
+
+// checkHoldActive rejects holds that are no longer active, and active holds
+// past their expiry with ErrHoldExpired.
+func checkHoldActive(hold Hold) error {
+	if hold.Status != HoldStatusActive {
+		return fmt.Errorf("hold is %s: %w", hold.Status, ErrHoldNotActive)
+	}
+
+	if !hold.ExpiresAt.After(time.Now()) {
+		return ErrHoldExpired
+	}
+
+	return nil
+}
+
+// expireHold marks a locked hold expired and commits tx, so the status
+// sticks even though the capture or void that found it fails.
+func expireHold(ctx context.Context, tx pgx.Tx, holdID uuid.UUID) error {
+	_, err := tx.Exec(ctx, `
+		UPDATE holds SET status = 'expired', updated_at = NOW()
+		WHERE id = $1
+	`, holdID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+func lockHold(ctx context.Context, tx pgx.Tx, holdID uuid.UUID) (Hold, error) {
+	return scanHold(tx.QueryRow(ctx, holdSelect+` WHERE id = $1 FOR UPDATE`, holdID))
+}
+
+const holdColumns = `id, reference_id, wallet_id, amount, captured_amount,
+	status, capture_transaction_id, expires_at, created_at`
+
+const holdSelect = `SELECT ` + holdColumns + ` FROM holds`
+
+func scanHold(row pgx.Row) (Hold, error) {
+	var h Hold
+
+	err := row.Scan(
+		&h.ID,
+		&h.ReferenceID,
+		&h.WalletID,
+		&h.Amount,
+		&h.CapturedAmount,
+		&h.Status,
+		&h.CaptureTransactionID,
+		&h.ExpiresAt,
+		&h.CreatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return h, ErrHoldNotFound
+	}
+
+	return h, err
+}
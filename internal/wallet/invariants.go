@@ -0,0 +1,188 @@
+package wallet
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// maxInvariantRows caps each violation list, the counts stay exact.
+const maxInvariantRows = 100
+
+type UnbalancedTransaction struct {
+	TransactionID uuid.UUID `json:"transaction_id"`
+	ReferenceID   string    `json:"reference_id"`
+	Asset         string    `json:"asset"`
+	Debits        int64     `json:"debits"`
+	Credits       int64     `json:"credits"`
+}
+
+type TransactionRef struct {
+	TransactionID uuid.UUID `json:"transaction_id"`
+	ReferenceID   string    `json:"reference_id"`
+	Type          string    `json:"type"`
+}
+
+// AssetTrialBalance sums every ledger entry and cached balance of an asset.
+// In a closed double-entry ledger both Net and CachedTotal are zero, a non
+// zero UnbackedBalance is cached balance without ledger entries behind it.
+type AssetTrialBalance struct {
+	Asset           string `json:"asset"`
+	Debits          int64  `json:"debits"`
+	Credits         int64  `json:"credits"`
+	Net             int64  `json:"net"`
+	CachedTotal     int64  `json:"cached_total"`
+	UnbackedBalance int64  `json:"unbacked_balance"`
+}
+
+type InvariantReport struct {
+	CheckedAt time.Time `json:"checked_at"`
+	OK        bool      `json:"ok"`
+
+	UnbalancedCount        int                     `json:"unbalanced_count"`
+	UnbalancedTransactions []UnbalancedTransaction `json:"unbalanced_transactions"`
+
+	OrphanCount        int              `json:"orphan_count"`
+	OrphanTransactions []TransactionRef `json:"orphan_transactions"`
+
+	Assets []AssetTrialBalance `json:"assets"`
+}
+
+// CheckInvariants verifies the double-entry invariants of the whole ledger
+// in one repeatable read snapshot.
+func (r *Repository) CheckInvariants(ctx context.Context) (InvariantReport, error) {
+
+	report := InvariantReport{
+		CheckedAt:              time.Now().UTC(),
+		UnbalancedTransactions: []UnbalancedTransaction{},
+		OrphanTransactions:     []TransactionRef{},
+		Assets:                 []AssetTrialBalance{},
+	}
+
+	tx, err := r.pool.BeginTx(ctx, pgx.TxOptions{
+		IsoLevel:   pgx.RepeatableRead,
+		AccessMode: pgx.ReadOnly,
+	})
+	if err != nil {
+		return report, err
+	}
+	defer tx.Rollback(ctx)
+
+	// debits equal credits per asset within every transaction, a journal may
+	// move several assets as long as each one balances
+
+	rows, err := tx.Query(ctx, `
+		SELECT t.id, t.reference_id, a.code,
+		       SUM(CASE WHEN e.direction = 'debit' THEN e.amount ELSE 0 END) AS debits,
+		       SUM(CASE WHEN e.direction = 'credit' THEN e.amount ELSE 0 END) AS credits,
+		       COUNT(*) OVER ()
+		FROM transactions t
+		JOIN ledger_entries e ON e.transaction_id = t.id
+		JOIN wallets w ON w.id = e.wallet_id
+		JOIN assets a ON a.id = w.asset_type_id
+		GROUP BY t.id, t.reference_id, a.code
+		HAVING SUM(CASE WHEN e.direction = 'debit' THEN e.amount ELSE -e.amount END) <> 0
+		ORDER BY t.created_at, t.id
+		LIMIT $1
+	`, maxInvariantRows)
+	if err != nil {
+		return report, err
+	}
+	for rows.Next() {
+		var u UnbalancedTransaction
+		if err := rows.Scan(&u.TransactionID, &u.ReferenceID, &u.Asset, &u.Debits, &u.Credits, &report.UnbalancedCount); err != nil {
+			rows.Close()
+			return report, err
+		}
+		report.UnbalancedTransactions = append(report.UnbalancedTransactions, u)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return report, err
+	}
+
+	// every transaction has ledger entries, except balance repairs which
+	// correct the cache and leave the ledger alone
+
+	report.OrphanTransactions, report.OrphanCount, err = queryTransactionRefs(ctx, tx, `
+		SELECT t.id, t.reference_id, t.type, COUNT(*) OVER ()
+		FROM transactions t
+		WHERE t.repairs_wallet_id IS NULL
+		  AND NOT EXISTS (
+		      SELECT 1 FROM ledger_entries e WHERE e.transaction_id = t.id
+		  )
+		ORDER BY t.created_at, t.id
+		LIMIT $1
+	`)
+	if err != nil {
+		return report, err
+	}
+
+	// per asset trial balance
+
+	rows, err = tx.Query(ctx, `
+		SELECT a.code,
+		       COALESCE(SUM(CASE WHEN e.direction = 'debit' THEN e.amount END), 0),
+		       COALESCE(SUM(CASE WHEN e.direction = 'credit' THEN e.amount END), 0),
+		       COALESCE((SELECT SUM(balance) FROM wallets cw WHERE cw.asset_type_id = a.id), 0)
+		FROM assets a
+		LEFT JOIN wallets w ON w.asset_type_id = a.id
+		LEFT JOIN ledger_entries e ON e.wallet_id = w.id
+		GROUP BY a.id, a.code
+		ORDER BY a.code
+	`)
+	if err != nil {
+		return report, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var b AssetTrialBalance
+		if err := rows.Scan(&b.Asset, &b.Debits, &b.Credits, &b.CachedTotal); err != nil {
+			return report, err
+		}
+		b.Net = b.Credits - b.Debits
+		b.UnbackedBalance = b.CachedTotal - b.Net
+		report.Assets = append(report.Assets, b)
+	}
+	if err := rows.Err(); err != nil {
+		return report, err
+	}
+
+	report.OK = report.UnbalancedCount == 0 && report.OrphanCount == 0
+	for _, b := range report.Assets {
+		if b.Net != 0 || b.CachedTotal != 0 {
+			report.OK = false
+		}
+	}
+
+	return report, nil
+}
+
+func queryTransactionRefs(
+	ctx context.Context,
+	tx pgx.Tx,
+	query string,
+) ([]TransactionRef, int, error) {
+
+	rows, err := tx.Query(ctx, query, maxInvariantRows)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	refs := []TransactionRef{}
+	var total int
+
+	for rows.Next() {
+		var t TransactionRef
+		if err := rows.Scan(&t.TransactionID, &t.ReferenceID, &t.Type, &total); err != nil {
+			return nil, 0, err
+		}
+		refs = append(refs, t)
+	}
+
+	return refs, total, rows.Err()
+}
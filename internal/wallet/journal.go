@@ -0,0 +1,394 @@
+package wallet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+type lockedWallet struct {
+	assetTypeID   int
+	balance       int64
+	held          int64
+	allowNegative bool
+	overdraft     int64
+	status        string
+	blockCredits  bool
+}
+
+// available is the part of the balance not reserved by active holds.
+func (w lockedWallet) available() int64 {
+	return w.balance - w.held
+}
+
+// covers reports whether the wallet can be debited amount, going no further
+// below zero than its overdraft limit.
+func (w lockedWallet) covers(amount int64) bool {
+	return w.allowNegative || w.available()+w.overdraft >= amount
+}
+
+func (w lockedWallet) insufficientFunds(id uuid.UUID, requested int64) error {
+	return &InsufficientFundsError{
+		WalletID:       id,
+		Balance:        w.available(),
+		OverdraftLimit: w.overdraft,
+		Requested:      requested,
+	}
+}
+
+// checkStatus rejects debits from wallets that are not active, and credits
+// to closed wallets or frozen wallets that block credits.
+func (w lockedWallet) checkStatus(id uuid.UUID, debit bool, credit bool) error {
+	switch {
+	case w.status == WalletStatusClosed && (debit || credit):
+		return fmt.Errorf("wallet %s: %w", id, ErrWalletClosed)
+	case w.status == WalletStatusFrozen && (debit || (credit && w.blockCredits)):
+		return fmt.Errorf("wallet %s: %w", id, ErrWalletFrozen)
+	}
+	return nil
+}
+
+// journal is a transaction to be posted, reversesID links a compensating
+// transaction to the one it reverses. An administrative journal, such as
+// the sweep of a wallet being closed, may debit frozen wallets.
+type journal struct {
+	referenceID    string
+	txType         string
+	legs           []Posting
+	reversesID     *uuid.UUID
+	administrative bool
+}
+
+// PostJournal atomically posts a balanced set of ledger legs as a single
+// transaction and returns its id. Debits must equal credits per asset.
+// Replaying a reference_id with the same type and legs returns the original
+// transaction, any other reuse fails with ErrIdempotencyConflict.
+func (r *Repository) PostJournal(
+	ctx context.Context,
+	referenceID string,
+	txType string,
+	legs []Posting,
+) (uuid.UUID, error) {
+
+	tx, err := r.pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return uuid.Nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	txnID, err := postJournal(ctx, tx, journal{
+		referenceID: referenceID,
+		txType:      txType,
+		legs:        legs,
+	})
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	return txnID, tx.Commit(ctx)
+}
+
+// postJournal posts j inside tx, the caller commits.
+func postJournal(ctx context.Context, tx pgx.Tx, j journal) (uuid.UUID, error) {
+
+	referenceID, txType, legs := j.referenceID, j.txType, j.legs
+
+	if len(legs) < 2 {
+		return uuid.Nil, fmt.Errorf("journal needs at least two legs: %w", ErrInvalidPosting)
+	}
+	for _, leg := range legs {
+		if leg.Amount <= 0 {
+			return uuid.Nil, ErrInvalidAmount
+		}
+		if leg.Direction != DirectionDebit && leg.Direction != DirectionCredit {
+			return uuid.Nil, fmt.Errorf("unknown direction %q: %w", leg.Direction, ErrInvalidPosting)
+		}
+	}
+
+	// check if transaction already completed
+
+	existingID, err := replayJournal(ctx, tx, referenceID, txType, legs)
+	if err == nil {
+		return existingID, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return uuid.Nil, err
+	}
+
+	// Lock wallets in deterministic order
+
+	walletIDs := journalWalletIDs(legs)
+
+	wallets, err := lockWallets(ctx, tx, walletIDs)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	// Check debits equal credits per asset
+
+	net := make(map[uuid.UUID]int64)
+	perAsset := make(map[int]int64)
+
+	for _, leg := range legs {
+		net[leg.WalletID] += leg.signedAmount()
+		perAsset[wallets[leg.WalletID].assetTypeID] += leg.signedAmount()
+	}
+
+	for assetTypeID, sum := range perAsset {
+		if sum != 0 {
+			return uuid.Nil, fmt.Errorf("asset %d off by %d: %w", assetTypeID, sum, ErrUnbalancedJournal)
+		}
+	}
+
+	// Check wallet status, frozen and closed wallets cannot move funds
+
+	if !j.administrative {
+		debited := make(map[uuid.UUID]bool)
+		credited := make(map[uuid.UUID]bool)
+		for _, leg := range legs {
+			if leg.Direction == DirectionDebit {
+				debited[leg.WalletID] = true
+			} else {
+				credited[leg.WalletID] = true
+			}
+		}
+
+		for _, id := range walletIDs {
+			if err := wallets[id].checkStatus(id, debited[id], credited[id]); err != nil {
+				return uuid.Nil, err
+			}
+		}
+	}
+
+	// Check available balance, funds reserved by holds cannot be debited
+
+	for _, id := range walletIDs {
+		if net[id] < 0 && !wallets[id].covers(-net[id]) {
+			return uuid.Nil, wallets[id].insufficientFunds(id, -net[id])
+		}
+	}
+
+	// Check velocity limits of the user wallets moved
+
+	if !j.administrative {
+		if err := checkLimits(ctx, tx, txType, walletIDs, legs); err != nil {
+			return uuid.Nil, err
+		}
+	}
+
+	// Create transaction record
+
+	txnID := uuid.New()
+	fromWalletID, toWalletID, amount := transferSummary(legs)
+
+	_, err = tx.Exec(ctx,
+		`INSERT INTO transactions
+			(id, reference_id, type, status, from_wallet_id, to_wallet_id, amount,
+			 reverses_transaction_id)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		txnID,
+		referenceID,
+		txType,
+		StatusCompleted,
+		fromWalletID,
+		toWalletID,
+		amount,
+		j.reversesID,
+	)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	// Insert ledger entries
+
+	for _, leg := range legs {
+		_, err = tx.Exec(ctx,
+			`INSERT INTO ledger_entries
+				(id, transaction_id, wallet_id, direction, amount)
+			 VALUES ($1, $2, $3, $4, $5)`,
+			uuid.New(),
+			txnID,
+			leg.WalletID,
+			leg.Direction,
+			leg.Amount,
+		)
+		if err != nil {
+			return uuid.Nil, err
+		}
+	}
+
+	// Update cached balances
+
+	for _, id := range walletIDs {
+		if net[id] == 0 {
+			continue
+		}
+
+		_, err = tx.Exec(ctx,
+			`UPDATE wallets
+			 SET balance = balance + $1
+			 WHERE id = $2`,
+			net[id],
+			id,
+		)
+		if err != nil {
+			return uuid.Nil, err
+		}
+	}
+
+	// Record the ledger event in the same transaction
+
+	balances := make(map[uuid.UUID]int64, len(walletIDs))
+	for _, id := range walletIDs {
+		balances[id] = wallets[id].balance + net[id]
+	}
+
+	if err := insertLedgerEvent(ctx, tx, txnID, j, wallets, balances); err != nil {
+		return uuid.Nil, err
+	}
+
+	return txnID, nil
+}
+
+// replayJournal returns the id of the transaction already recorded under
+// referenceID, or pgx.ErrNoRows when there is none. The recorded type and
+// ledger entries must match the requested legs exactly.
+func replayJournal(
+	ctx context.Context,
+	tx pgx.Tx,
+	referenceID string,
+	txType string,
+	legs []Posting,
+) (uuid.UUID, error) {
+
+	var (
+		existingID   uuid.UUID
+		existingType string
+	)
+	err := tx.QueryRow(ctx,
+		`SELECT id, type FROM transactions WHERE reference_id = $1`,
+		referenceID,
+	).Scan(&existingID, &existingType)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	if existingType != txType {
+		return uuid.Nil, ErrIdempotencyConflict
+	}
+
+	rows, err := tx.Query(ctx,
+		`SELECT wallet_id, direction, amount
+		 FROM ledger_entries WHERE transaction_id = $1`,
+		existingID,
+	)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	defer rows.Close()
+
+	remaining := make(map[Posting]int)
+	for _, leg := range legs {
+		remaining[leg]++
+	}
+
+	var recorded int
+	for rows.Next() {
+		var p Posting
+		if err := rows.Scan(&p.WalletID, &p.Direction, &p.Amount); err != nil {
+			return uuid.Nil, err
+		}
+		remaining[p]--
+		recorded++
+	}
+	if err := rows.Err(); err != nil {
+		return uuid.Nil, err
+	}
+
+	if recorded != len(legs) {
+		return uuid.Nil, ErrIdempotencyConflict
+	}
+	for _, n := range remaining {
+		if n != 0 {
+			return uuid.Nil, ErrIdempotencyConflict
+		}
+	}
+
+	return existingID, nil
+}
+
+// lockWallets takes row locks on the wallets in the given order and returns
+// their asset, balance, status and amount held by active holds.
+func lockWallets(
+	ctx context.Context,
+	tx pgx.Tx,
+	walletIDs []uuid.UUID,
+) (map[uuid.UUID]lockedWallet, error) {
+
+	wallets := make(map[uuid.UUID]lockedWallet, len(walletIDs))
+
+	for _, id := range walletIDs {
+		var w lockedWallet
+		err := tx.QueryRow(ctx,
+			`SELECT asset_type_id, balance, allow_negative, overdraft_limit,
+			        status, block_credits
+			 FROM wallets WHERE id = $1 FOR UPDATE`,
+			id,
+		).Scan(&w.assetTypeID, &w.balance, &w.allowNegative, &w.overdraft, &w.status, &w.blockCredits)
+		if err == nil {
+			w.held, err = heldAmount(ctx, tx, id)
+		}
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return nil, fmt.Errorf("wallet with id %s not found: %w", id, ErrWalletNotFound)
+			}
+			return nil, err
+		}
+		wallets[id] = w
+	}
+
+	return wallets, nil
+}
+
+// journalWalletIDs returns the distinct wallets of the legs sorted so that
+// concurrent journals always lock them in the same order.
+func journalWalletIDs(legs []Posting) []uuid.UUID {
+	seen := make(map[uuid.UUID]bool)
+	var ids []uuid.UUID
+
+	for _, leg := range legs {
+		if !seen[leg.WalletID] {
+			seen[leg.WalletID] = true
+			ids = append(ids, leg.WalletID)
+		}
+	}
+
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i].String() < ids[j].String()
+	})
+
+	return ids
+}
+
+// transferSummary fills the from/to/amount columns of a transaction when the
+// journal is a plain one debit, one credit transfer.
+func transferSummary(legs []Posting) (*uuid.UUID, *uuid.UUID, *int64) {
+	if len(legs) != 2 || legs[0].Amount != legs[1].Amount {
+		return nil, nil, nil
+	}
+
+	debit, credit := legs[0], legs[1]
+	if debit.Direction == DirectionCredit {
+		debit, credit = credit, debit
+	}
+	if debit.Direction != DirectionDebit || credit.Direction != DirectionCredit {
+		return nil, nil, nil
+	}
+
+	amount := debit.Amount
+	return &debit.WalletID, &credit.WalletID, &amount
+}
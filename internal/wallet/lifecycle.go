@@ -0,0 +1,393 @@
+package wallet
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	WalletStatusActive = "active"
+	WalletStatusFrozen = "frozen"
+	WalletStatusClosed = "closed"
+)
+
+type WalletState struct {
+	WalletID     uuid.UUID `json:"wallet_id"`
+	Status       string    `json:"status"`
+	BlockCredits bool      `json:"block_credits"`
+
+	ClosingTransactionID *uuid.UUID `json:"closing_transaction_id,omitempty"`
+}
+
+type WalletStatusChange struct {
+	ID                   int64      `json:"id"`
+	WalletID             uuid.UUID  `json:"wallet_id"`
+	FromStatus           string     `json:"from_status"`
+	ToStatus             string     `json:"to_status"`
+	BlockCredits         bool       `json:"block_credits"`
+	Actor                string     `json:"actor"`
+	Reason               string     `json:"reason"`
+	ClosingTransactionID *uuid.UUID `json:"closing_transaction_id"`
+	CreatedAt            time.Time  `json:"created_at"`
+}
+
+// statusChange is a requested wallet status change, made by actor.
+type statusChange struct {
+	to           string
+	blockCredits bool
+	actor        string
+	reason       string
+}
+
+func (c statusChange) validate() error {
+	if strings.TrimSpace(c.reason) == "" {
+		return fmt.Errorf("%w: reason is required", ErrInvalidStatusChange)
+	}
+	if strings.TrimSpace(c.actor) == "" {
+		return fmt.Errorf("%w: actor is required", ErrInvalidStatusChange)
+	}
+	return nil
+}
+
+// FreezeWallet stops a wallet from being debited, and from being credited
+// when blockCredits is set. Freezing a frozen wallet updates blockCredits.
+func (r *Repository) FreezeWallet(
+	ctx context.Context,
+	walletID uuid.UUID,
+	blockCredits bool,
+	actor string,
+	reason string,
+) (WalletState, error) {
+	return r.changeWalletStatus(ctx, walletID, statusChange{
+		to:           WalletStatusFrozen,
+		blockCredits: blockCredits,
+		actor:        actor,
+		reason:       reason,
+	})
+}
+
+// UnfreezeWallet makes a frozen wallet active again, an active wallet is
+// left alone.
+func (r *Repository) UnfreezeWallet(
+	ctx context.Context,
+	walletID uuid.UUID,
+	actor string,
+	reason string,
+) (WalletState, error) {
+	return r.changeWalletStatus(ctx, walletID, statusChange{
+		to:     WalletStatusActive,
+		actor:  actor,
+		reason: reason,
+	})
+}
+
+func (r *Repository) changeWalletStatus(
+	ctx context.Context,
+	walletID uuid.UUID,
+	change statusChange,
+) (WalletState, error) {
+
+	if err := change.validate(); err != nil {
+		return WalletState{}, err
+	}
+
+	tx, err := r.pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return WalletState{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	wallets, err := lockWallets(ctx, tx, []uuid.UUID{walletID})
+	if err != nil {
+		return WalletState{}, err
+	}
+
+	state, err := applyStatusChange(ctx, tx, walletID, wallets[walletID], change, nil)
+	if err != nil {
+		return WalletState{}, err
+	}
+
+	return state, tx.Commit(ctx)
+}
+
+// FreezeUserWallets freezes every wallet of a user that is not closed. The
+// user stays frozen, wallets created later start frozen too.
+func (r *Repository) FreezeUserWallets(
+	ctx context.Context,
+	userID uuid.UUID,
+	blockCredits bool,
+	actor string,
+	reason string,
+) ([]WalletState, error) {
+	return r.changeUserWalletStatus(ctx, userID, statusChange{
+		to:           WalletStatusFrozen,
+		blockCredits: blockCredits,
+		actor:        actor,
+		reason:       reason,
+	})
+}
+
+// UnfreezeUserWallets makes the user and every frozen wallet of the user
+// active again.
+func (r *Repository) UnfreezeUserWallets(
+	ctx context.Context,
+	userID uuid.UUID,
+	actor string,
+	reason string,
+) ([]WalletState, error) {
+	return r.changeUserWalletStatus(ctx, userID, statusChange{
+		to:     WalletStatusActive,
+		actor:  actor,
+		reason: reason,
+	})
+}
+
+func (r *Repository) changeUserWalletStatus(
+	ctx context.Context,
+	userID uuid.UUID,
+	change statusChange,
+) ([]WalletState, error) {
+
+	if err := change.validate(); err != nil {
+		return nil, err
+	}
+
+	tx, err := r.pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	// the user row lock orders this against CreateWallet, so a wallet
+	// created concurrently is either listed below or created frozen
+	tag, err := tx.Exec(ctx,
+		`UPDATE users SET status = $1, block_credits = $2 WHERE id = $3`,
+		change.to,
+		change.blockCredits,
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if tag.RowsAffected() == 0 {
+		return nil, fmt.Errorf("user with id %s not found: %w", userID, ErrUserNotFound)
+	}
+
+	rows, err := tx.Query(ctx,
+		`SELECT id FROM wallets WHERE user_id = $1 AND status <> 'closed'`,
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var legs []Posting
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		legs = append(legs, Posting{WalletID: id})
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// same lock order as journals
+	ids := journalWalletIDs(legs)
+
+	wallets, err := lockWallets(ctx, tx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	states := []WalletState{}
+
+	for _, id := range ids {
+		if wallets[id].status == WalletStatusClosed {
+			continue
+		}
+
+		state, err := applyStatusChange(ctx, tx, id, wallets[id], change, nil)
+		if err != nil {
+			return nil, err
+		}
+		states = append(states, state)
+	}
+
+	return states, tx.Commit(ctx)
+}
+
+// CloseWallet closes a user wallet for good. A wallet with active holds
+// cannot be closed. A wallet with a balance cannot be closed either, unless
+// sweepTo is set: the balance is then moved there by a closure transaction
+// in the same database transaction.
+func (r *Repository) CloseWallet(
+	ctx context.Context,
+	walletID uuid.UUID,
+	sweepTo *uuid.UUID,
+	actor string,
+	reason string,
+) (WalletState, error) {
+
+	change := statusChange{to: WalletStatusClosed, actor: actor, reason: reason}
+	if err := change.validate(); err != nil {
+		return WalletState{}, err
+	}
+
+	tx, err := r.pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return WalletState{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	var system bool
+	err = tx.QueryRow(ctx,
+		`SELECT EXISTS (SELECT 1 FROM system_wallets WHERE wallet_id = $1)`,
+		walletID,
+	).Scan(&system)
+	if err != nil {
+		return WalletState{}, err
+	}
+	if system {
+		return WalletState{}, fmt.Errorf("system wallets cannot be closed: %w", ErrNotUserWallet)
+	}
+
+	wallets, err := lockWallets(ctx, tx, []uuid.UUID{walletID})
+	if err != nil {
+		return WalletState{}, err
+	}
+	w := wallets[walletID]
+
+	if w.status == WalletStatusClosed {
+		return WalletState{WalletID: walletID, Status: w.status}, tx.Commit(ctx)
+	}
+
+	if w.held > 0 {
+		return WalletState{}, fmt.Errorf("%d held by active holds: %w", w.held, ErrWalletNotEmpty)
+	}
+
+	var closingID *uuid.UUID
+
+	if w.balance != 0 {
+		if sweepTo == nil || w.balance < 0 {
+			return WalletState{}, fmt.Errorf("balance is %d: %w", w.balance, ErrWalletNotEmpty)
+		}
+
+		txnID, err := postJournal(ctx, tx, journal{
+			referenceID: "closure:" + walletID.String(),
+			txType:      TxTypeClosure,
+			legs: []Posting{
+				{WalletID: walletID, Direction: DirectionDebit, Amount: w.balance},
+				{WalletID: *sweepTo, Direction: DirectionCredit, Amount: w.balance},
+			},
+			administrative: true,
+		})
+		if err != nil {
+			return WalletState{}, err
+		}
+		closingID = &txnID
+	}
+
+	state, err := applyStatusChange(ctx, tx, walletID, w, change, closingID)
+	if err != nil {
+		return WalletState{}, err
+	}
+
+	return state, tx.Commit(ctx)
+}
+
+// applyStatusChange moves a locked wallet to a new status and records the
+// change. A change that leaves the wallet as it is records nothing.
+func applyStatusChange(
+	ctx context.Context,
+	tx pgx.Tx,
+	walletID uuid.UUID,
+	w lockedWallet,
+	change statusChange,
+	closingID *uuid.UUID,
+) (WalletState, error) {
+
+	state := WalletState{
+		WalletID:             walletID,
+		Status:               change.to,
+		BlockCredits:         change.blockCredits,
+		ClosingTransactionID: closingID,
+	}
+
+	if w.status == WalletStatusClosed {
+		return state, fmt.Errorf("wallet %s: %w", walletID, ErrWalletClosed)
+	}
+	if w.status == change.to && w.blockCredits == change.blockCredits {
+		return state, nil
+	}
+
+	_, err := tx.Exec(ctx, `
+		UPDATE wallets SET status = $1, block_credits = $2
+		WHERE id = $3
+	`, change.to, change.blockCredits, walletID)
+	if err != nil {
+		return state, err
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO wallet_status_changes
+			(wallet_id, from_status, to_status, block_credits, actor, reason, closing_transaction_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`, walletID, w.status, change.to, change.blockCredits, change.actor, change.reason, closingID)
+
+	return state, err
+}
+
+// GetWalletStatusHistory returns the status changes of a wallet, oldest
+// first.
+func (r *Repository) GetWalletStatusHistory(
+	ctx context.Context,
+	walletID uuid.UUID,
+) ([]WalletStatusChange, error) {
+
+	if _, err := r.GetWalletUserID(ctx, walletID); err != nil {
+		return nil, err
+	}
+
+	rows, err := r.pool.Query(ctx, `
+		SELECT id, wallet_id, from_status, to_status, block_credits,
+		       actor, reason, closing_transaction_id, created_at
+		FROM wallet_status_changes
+		WHERE wallet_id = $1
+		ORDER BY id
+	`, walletID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	changes := []WalletStatusChange{}
+
+	for rows.Next() {
+		var c WalletStatusChange
+		if err := rows.Scan(
+			&c.ID,
+			&c.WalletID,
+			&c.FromStatus,
+			&c.ToStatus,
+			&c.BlockCredits,
+			&c.Actor,
+			&c.Reason,
+			&c.ClosingTransactionID,
+			&c.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		changes = append(changes, c)
+	}
+
+	return changes, rows.Err()
+}
@@ -0,0 +1,427 @@
+package wallet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	LimitWindowHour  = "hour"
+	LimitWindowDay   = "day"
+	LimitWindowWeek  = "week"
+	LimitWindowMonth = "month"
+)
+
+// limitWindowHours is the length of each rolling window.
+var limitWindowHours = map[string]int{
+	LimitWindowHour:  1,
+	LimitWindowDay:   24,
+	LimitWindowWeek:  7 * 24,
+	LimitWindowMonth: 30 * 24,
+}
+
+// limitTxTypes are the transaction types a velocity limit can apply to.
+var limitTxTypes = map[string]bool{
+	TxTypeTopup:    true,
+	TxTypeBonus:    true,
+	TxTypeSpend:    true,
+	TxTypeTransfer: true,
+	TxTypeCapture:  true,
+}
+
+// VelocityLimit caps the amount and count of one transaction type moving a
+// user wallet in one direction within a rolling window. WalletID is set on
+// per wallet overrides, which replace the asset default of the same type,
+// direction and window.
+type VelocityLimit struct {
+	ID        uuid.UUID  `json:"id"`
+	Asset     string     `json:"asset"`
+	WalletID  *uuid.UUID `json:"wallet_id,omitempty"`
+	TxType    string     `json:"tx_type"`
+	Direction string     `json:"direction"`
+	Window    string     `json:"window"`
+	MaxAmount *int64     `json:"max_amount"`
+	MaxCount  *int64     `json:"max_count"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// lifted reports whether the limit is an override without maximums.
+func (l VelocityLimit) lifted() bool {
+	return l.MaxAmount == nil && l.MaxCount == nil
+}
+
+// VelocityLimitInput sets an asset default when Asset is given, or a wallet
+// override when WalletID is.
+type VelocityLimitInput struct {
+	Asset     string
+	WalletID  *uuid.UUID
+	TxType    string
+	Direction string
+	Window    string
+	MaxAmount *int64
+	MaxCount  *int64
+}
+
+func (in VelocityLimitInput) validate() error {
+	switch {
+	case (in.Asset == "") == (in.WalletID == nil):
+		return fmt.Errorf("%w: exactly one of asset or wallet_id is required", ErrInvalidLimit)
+	case !limitTxTypes[in.TxType]:
+		return fmt.Errorf("%w: unsupported tx_type %q", ErrInvalidLimit, in.TxType)
+	case in.Direction != DirectionDebit && in.Direction != DirectionCredit:
+		return fmt.Errorf("%w: direction must be debit or credit", ErrInvalidLimit)
+	case limitWindowHours[in.Window] == 0:
+		return fmt.Errorf("%w: window must be hour, day, week or month", ErrInvalidLimit)
+	case in.MaxAmount != nil && *in.MaxAmount <= 0,
+		in.MaxCount != nil && *in.MaxCount <= 0:
+		return fmt.Errorf("%w: maximums must be positive", ErrInvalidLimit)
+	case in.WalletID == nil && in.MaxAmount == nil && in.MaxCount == nil:
+		return fmt.Errorf("%w: an asset default needs max_amount or max_count", ErrInvalidLimit)
+	}
+	return nil
+}
+
+// LimitUsage is an effective limit of a wallet with what the current window
+// has used. Remaining is nil when there is no maximum.
+type LimitUsage struct {
+	VelocityLimit
+	UsedAmount      int64  `json:"used_amount"`
+	UsedCount       int64  `json:"used_count"`
+	RemainingAmount *int64 `json:"remaining_amount"`
+	RemainingCount  *int64 `json:"remaining_count"`
+}
+
+func newLimitUsage(l VelocityLimit, amount int64, count int64) LimitUsage {
+	u := LimitUsage{VelocityLimit: l, UsedAmount: amount, UsedCount: count}
+	if l.MaxAmount != nil {
+		remaining := max(*l.MaxAmount-amount, 0)
+		u.RemainingAmount = &remaining
+	}
+	if l.MaxCount != nil {
+		remaining := max(*l.MaxCount-count, 0)
+		u.RemainingCount = &remaining
+	}
+	return u
+}
+
+const velocityLimitColumns = `
+	l.id, a.code, l.wallet_id, l.tx_type, l.direction, l.period,
+	l.max_amount, l.max_count, l.created_at, l.updated_at`
+
+func scanVelocityLimit(row pgx.Row) (VelocityLimit, error) {
+	var l VelocityLimit
+	err := row.Scan(
+		&l.ID,
+		&l.Asset,
+		&l.WalletID,
+		&l.TxType,
+		&l.Direction,
+		&l.Window,
+		&l.MaxAmount,
+		&l.MaxCount,
+		&l.CreatedAt,
+		&l.UpdatedAt,
+	)
+	return l, err
+}
+
+// SetVelocityLimit creates or replaces the limit for the asset or wallet,
+// type, direction and window of in.
+func (r *Repository) SetVelocityLimit(ctx context.Context, in VelocityLimitInput) (VelocityLimit, error) {
+
+	if err := in.validate(); err != nil {
+		return VelocityLimit{}, err
+	}
+
+	var (
+		assetTypeID *int
+		conflict    string
+	)
+
+	if in.WalletID != nil {
+		var userID *uuid.UUID
+		err := r.pool.QueryRow(ctx,
+			`SELECT user_id FROM wallets WHERE id = $1`,
+			*in.WalletID,
+		).Scan(&userID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return VelocityLimit{}, fmt.Errorf("wallet with id %s not found: %w", *in.WalletID, ErrWalletNotFound)
+		}
+		if err != nil {
+			return VelocityLimit{}, err
+		}
+		if userID == nil {
+			return VelocityLimit{}, fmt.Errorf("limits apply to user wallets only: %w", ErrNotUserWallet)
+		}
+		conflict = `(wallet_id, tx_type, direction, period) WHERE wallet_id IS NOT NULL`
+	} else {
+		var id int
+		err := r.pool.QueryRow(ctx,
+			`SELECT id FROM assets WHERE code = $1`,
+			in.Asset,
+		).Scan(&id)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return VelocityLimit{}, ErrAssetNotFound
+		}
+		if err != nil {
+			return VelocityLimit{}, err
+		}
+		assetTypeID = &id
+		conflict = `(asset_type_id, tx_type, direction, period) WHERE asset_type_id IS NOT NULL`
+	}
+
+	var id uuid.UUID
+	err := r.pool.QueryRow(ctx, `
+		INSERT INTO velocity_limits
+			(id, asset_type_id, wallet_id, tx_type, direction, period, max_amount, max_count)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT `+conflict+` DO UPDATE
+		SET max_amount = EXCLUDED.max_amount,
+		    max_count = EXCLUDED.max_count,
+		    updated_at = NOW()
+		RETURNING id
+	`,
+		uuid.New(),
+		assetTypeID,
+		in.WalletID,
+		in.TxType,
+		in.Direction,
+		in.Window,
+		in.MaxAmount,
+		in.MaxCount,
+	).Scan(&id)
+	if err != nil {
+		return VelocityLimit{}, err
+	}
+
+	return r.getVelocityLimit(ctx, id)
+}
+
+func (r *Repository) getVelocityLimit(ctx context.Context, id uuid.UUID) (VelocityLimit, error) {
+	l, err := scanVelocityLimit(r.pool.QueryRow(ctx, `
+		SELECT `+velocityLimitColumns+`
+		FROM velocity_limits l
+		LEFT JOIN wallets w ON w.id = l.wallet_id
+		JOIN assets a ON a.id = COALESCE(l.asset_type_id, w.asset_type_id)
+		WHERE l.id = $1
+	`, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return VelocityLimit{}, ErrLimitNotFound
+	}
+	return l, err
+}
+
+// ListVelocityLimits returns the asset defaults and wallet overrides,
+// optionally only those of one asset or one wallet.
+func (r *Repository) ListVelocityLimits(
+	ctx context.Context,
+	assetCode *string,
+	walletID *uuid.UUID,
+) ([]VelocityLimit, error) {
+
+	rows, err := r.pool.Query(ctx, `
+		SELECT `+velocityLimitColumns+`
+		FROM velocity_limits l
+		LEFT JOIN wallets w ON w.id = l.wallet_id
+		JOIN assets a ON a.id = COALESCE(l.asset_type_id, w.asset_type_id)
+		WHERE ($1::text IS NULL OR a.code = $1)
+		  AND ($2::uuid IS NULL OR l.wallet_id = $2)
+		ORDER BY a.code, l.wallet_id NULLS FIRST, l.tx_type, l.direction,
+		         array_position(ARRAY['hour', 'day', 'week', 'month'], l.period)
+	`, assetCode, walletID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	limits := []VelocityLimit{}
+
+	for rows.Next() {
+		l, err := scanVelocityLimit(rows)
+		if err != nil {
+			return nil, err
+		}
+		limits = append(limits, l)
+	}
+
+	return limits, rows.Err()
+}
+
+func (r *Repository) DeleteVelocityLimit(ctx context.Context, id uuid.UUID) error {
+	tag, err := r.pool.Exec(ctx,
+		`DELETE FROM velocity_limits WHERE id = $1`,
+		id,
+	)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrLimitNotFound
+	}
+	return nil
+}
+
+// GetWalletLimits returns the effective limits of a wallet with their usage
+// in the current windows. Lifted defaults are left out.
+func (r *Repository) GetWalletLimits(ctx context.Context, walletID uuid.UUID) ([]LimitUsage, error) {
+
+	var exists bool
+	err := r.pool.QueryRow(ctx,
+		`SELECT EXISTS (SELECT 1 FROM wallets WHERE id = $1)`,
+		walletID,
+	).Scan(&exists)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("wallet with id %s not found: %w", walletID, ErrWalletNotFound)
+	}
+
+	limits, err := effectiveLimits(ctx, r.pool, walletID, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	usages := []LimitUsage{}
+
+	for _, l := range limits {
+		amount, count, err := limitUsed(ctx, r.pool, walletID, l)
+		if err != nil {
+			return nil, err
+		}
+		usages = append(usages, newLimitUsage(l, amount, count))
+	}
+
+	return usages, nil
+}
+
+// effectiveLimits returns the limits that apply to a user wallet, its own
+// overrides first and the asset defaults it does not override, optionally
+// of one type and direction. Lifted defaults are left out.
+func effectiveLimits(
+	ctx context.Context,
+	q querier,
+	walletID uuid.UUID,
+	txType *string,
+	direction *string,
+) ([]VelocityLimit, error) {
+
+	rows, err := q.Query(ctx, `
+		SELECT * FROM (
+			SELECT DISTINCT ON (l.tx_type, l.direction, l.period) `+velocityLimitColumns+`
+			FROM wallets w
+			JOIN assets a ON a.id = w.asset_type_id
+			JOIN velocity_limits l
+			  ON l.wallet_id = w.id OR l.asset_type_id = w.asset_type_id
+			WHERE w.id = $1
+			  AND w.user_id IS NOT NULL
+			  AND ($2::text IS NULL OR l.tx_type = $2)
+			  AND ($3::text IS NULL OR l.direction::text = $3)
+			ORDER BY l.tx_type, l.direction, l.period, l.wallet_id IS NULL
+		) l
+		ORDER BY l.tx_type, l.direction,
+		         array_position(ARRAY['hour', 'day', 'week', 'month'], l.period)
+	`, walletID, txType, direction)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var limits []VelocityLimit
+
+	for rows.Next() {
+		l, err := scanVelocityLimit(rows)
+		if err != nil {
+			return nil, err
+		}
+		if !l.lifted() {
+			limits = append(limits, l)
+		}
+	}
+
+	return limits, rows.Err()
+}
+
+// limitUsed sums the amount and counts the transactions of the limit's type
+// and direction on a wallet within its rolling window.
+func limitUsed(
+	ctx context.Context,
+	q querier,
+	walletID uuid.UUID,
+	l VelocityLimit,
+) (amount int64, count int64, err error) {
+
+	err = q.QueryRow(ctx, `
+		SELECT COALESCE(SUM(e.amount), 0), COUNT(DISTINCT e.transaction_id)
+		FROM ledger_entries e
+		JOIN transactions t ON t.id = e.transaction_id
+		WHERE e.wallet_id = $1
+		  AND e.direction::text = $2
+		  AND t.type = $3
+		  AND e.created_at > NOW() - make_interval(hours => $4::int)
+	`, walletID, l.Direction, l.TxType, limitWindowHours[l.Window]).Scan(&amount, &count)
+
+	return amount, count, err
+}
+
+// checkLimits enforces the velocity limits of the user wallets a journal
+// moves. It runs after the wallets are locked, so concurrent journals on a
+// wallet see each other's entries and cannot both use the same allowance.
+func checkLimits(
+	ctx context.Context,
+	tx pgx.Tx,
+	txType string,
+	walletIDs []uuid.UUID,
+	legs []Posting,
+) error {
+
+	if !limitTxTypes[txType] {
+		return nil
+	}
+
+	moved := make(map[uuid.UUID]map[string]int64)
+	for _, leg := range legs {
+		if moved[leg.WalletID] == nil {
+			moved[leg.WalletID] = make(map[string]int64)
+		}
+		moved[leg.WalletID][leg.Direction] += leg.Amount
+	}
+
+	for _, id := range walletIDs {
+		for _, direction := range []string{DirectionDebit, DirectionCredit} {
+			requested := moved[id][direction]
+			if requested == 0 {
+				continue
+			}
+
+			limits, err := effectiveLimits(ctx, tx, id, &txType, &direction)
+			if err != nil {
+				return err
+			}
+
+			for _, l := range limits {
+				amount, count, err := limitUsed(ctx, tx, id, l)
+				if err != nil {
+					return err
+				}
+
+				if (l.MaxAmount != nil && amount+requested > *l.MaxAmount) ||
+					(l.MaxCount != nil && count+1 > *l.MaxCount) {
+					return &LimitExceededError{
+						WalletID:  id,
+						Limit:     newLimitUsage(l, amount, count),
+						Requested: requested,
+					}
+				}
+			}
+		}
+	}
+
+	return nil
+}
@@ -7,11 +7,39 @@ import (
 )
 
 type Transaction struct {
-	ID          uuid.UUID `json:"id"`
-	ReferenceID string    `json:"reference_id"`
-	Type        string    `json:"type"`
-	Status      string    `json:"status"`
-	CreatedAt   time.Time `json:"created_at"`
+	ID           uuid.UUID  `json:"id"`
+	ReferenceID  string     `json:"reference_id"`
+	Type         string     `json:"type"`
+	Status       string     `json:"status"`
+	FromWalletID *uuid.UUID `json:"from_wallet_id"`
+	ToWalletID   *uuid.UUID `json:"to_wallet_id"`
+	Amount       *int64     `json:"amount"`
+	CreatedAt    time.Time  `json:"created_at"`
+
+	ReversesTransactionID *uuid.UUID `json:"reverses_transaction_id,omitempty"`
+	ReversedAmount        int64      `json:"reversed_amount"`
+}
+
+type Balance struct {
+	WalletID  uuid.UUID `json:"wallet_id"`
+	Balance   int64     `json:"balance"`
+	Held      int64     `json:"held"`
+	Available int64     `json:"available"`
+	Status    string    `json:"status"`
+
+	OverdraftLimit int64 `json:"overdraft_limit"`
+}
+
+type Hold struct {
+	ID                   uuid.UUID  `json:"id"`
+	ReferenceID          string     `json:"reference_id"`
+	WalletID             uuid.UUID  `json:"wallet_id"`
+	Amount               int64      `json:"amount"`
+	CapturedAmount       int64      `json:"captured_amount"`
+	Status               string     `json:"status"`
+	CaptureTransactionID *uuid.UUID `json:"capture_transaction_id"`
+	ExpiresAt            time.Time  `json:"expires_at"`
+	CreatedAt            time.Time  `json:"created_at"`
 }
 
 type LedgerEntry struct {
@@ -21,4 +49,47 @@ type LedgerEntry struct {
 	Direction     string    `json:"direction"`
 	Amount        int64     `json:"amount"`
 	CreatedAt     time.Time `json:"created_at"`
+}
+
+const (
+	DirectionDebit  = "debit"
+	DirectionCredit = "credit"
+)
+
+// Posting is a single leg of a journal.
+type Posting struct {
+	WalletID  uuid.UUID `json:"wallet_id"`
+	Direction string    `json:"direction"`
+	Amount    int64     `json:"amount"`
+}
+
+// signedAmount is the effect of the posting on the wallet balance.
+func (p Posting) signedAmount() int64 {
+	if p.Direction == DirectionDebit {
+		return -p.Amount
+	}
+	return p.Amount
+}
+
+type Statement struct {
+	WalletID       uuid.UUID        `json:"wallet_id"`
+	Asset          string           `json:"asset"`
+	From           time.Time        `json:"from"`
+	To             time.Time        `json:"to"`
+	OpeningBalance int64            `json:"opening_balance"`
+	ClosingBalance int64            `json:"closing_balance"`
+	Entries        []StatementEntry `json:"entries"`
+	HasMore        bool             `json:"has_more"`
+}
+
+type StatementEntry struct {
+	ID                    uuid.UUID   `json:"id"`
+	TransactionID         uuid.UUID   `json:"transaction_id"`
+	ReferenceID           string      `json:"reference_id"`
+	Type                  string      `json:"type"`
+	Direction             string      `json:"direction"`
+	Amount                int64       `json:"amount"`
+	CounterpartyWalletIDs []uuid.UUID `json:"counterparty_wallet_ids"`
+	RunningBalance        int64       `json:"running_balance"`
+	CreatedAt             time.Time   `json:"created_at"`
 }
\ No newline at end of file
@@ -0,0 +1,255 @@
+package wallet
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// LedgerEvent describes a posted transaction. Events are delivered at least
+// once, consumers deduplicate on ID.
+type LedgerEvent struct {
+	ID       uuid.UUID `json:"id"`
+	Sequence int64     `json:"sequence"`
+	Type     string    `json:"type"`
+
+	TransactionID         uuid.UUID      `json:"transaction_id"`
+	TransactionType       string         `json:"transaction_type"`
+	ReferenceID           string         `json:"reference_id"`
+	ReversesTransactionID *uuid.UUID     `json:"reverses_transaction_id,omitempty"`
+	Legs                  []Posting      `json:"legs"`
+	Balances              []EventBalance `json:"balances"`
+	OccurredAt            time.Time      `json:"occurred_at"`
+
+	// Attempts counts the deliveries tried before this one.
+	Attempts int `json:"-"`
+}
+
+// EventBalance is the cached balance of a wallet right after the event.
+type EventBalance struct {
+	WalletID uuid.UUID `json:"wallet_id"`
+	Asset    string    `json:"asset"`
+	Balance  int64     `json:"balance"`
+}
+
+// ledgerEventType is the event type of a transaction type, such as
+// ledger.topup.
+func ledgerEventType(txType string) string {
+	return "ledger." + txType
+}
+
+// insertLedgerEvent writes the event of a posted journal to the outbox
+// inside tx, so the event exists exactly when the transaction commits.
+// balances holds the cached balance after the journal of every wallet it
+// touched, which a balance repair does without legs.
+func insertLedgerEvent(
+	ctx context.Context,
+	tx pgx.Tx,
+	txnID uuid.UUID,
+	j journal,
+	wallets map[uuid.UUID]lockedWallet,
+	balances map[uuid.UUID]int64,
+) error {
+
+	assets, err := assetCodes(ctx, tx, wallets)
+	if err != nil {
+		return err
+	}
+
+	event := LedgerEvent{
+		ID:                    uuid.New(),
+		Type:                  ledgerEventType(j.txType),
+		TransactionID:         txnID,
+		TransactionType:       j.txType,
+		ReferenceID:           j.referenceID,
+		ReversesTransactionID: j.reversesID,
+		Legs:                  j.legs,
+		OccurredAt:            time.Now().UTC(),
+	}
+
+	var touched []Posting
+	for id := range balances {
+		touched = append(touched, Posting{WalletID: id})
+	}
+	walletIDs := journalWalletIDs(touched)
+
+	for _, id := range walletIDs {
+		event.Balances = append(event.Balances, EventBalance{
+			WalletID: id,
+			Asset:    assets[wallets[id].assetTypeID],
+			Balance:  balances[id],
+		})
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	var sequence int64
+	err = tx.QueryRow(ctx, `
+		INSERT INTO outbox_events (event_id, event_type, transaction_id, payload)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id
+	`, event.ID, event.Type, txnID, payload).Scan(&sequence)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO outbox_event_wallets (wallet_id, event_sequence)
+		SELECT unnest($1::uuid[]), $2
+	`, walletIDs, sequence)
+	if err != nil {
+		return err
+	}
+
+	// wake the event streams of these wallets on every instance, postgres
+	// delivers the notification on commit
+	_, err = tx.Exec(ctx,
+		`SELECT pg_notify($1, $2)`,
+		LedgerEventsChannel,
+		ledgerEventNotification(walletIDs),
+	)
+
+	return err
+}
+
+// LedgerEventsChannel is the LISTEN/NOTIFY channel of committed ledger
+// events. The payload is the comma separated ids of the wallets touched, or
+// "*" for journals too large to list.
+const LedgerEventsChannel = "ledger_events"
+
+// maxNotifiedWallets keeps the payload under the 8000 byte NOTIFY limit.
+const maxNotifiedWallets = 100
+
+func ledgerEventNotification(walletIDs []uuid.UUID) string {
+	if len(walletIDs) > maxNotifiedWallets {
+		return "*"
+	}
+
+	ids := make([]string, len(walletIDs))
+	for i, id := range walletIDs {
+		ids[i] = id.String()
+	}
+	return strings.Join(ids, ",")
+}
+
+func assetCodes(
+	ctx context.Context,
+	tx pgx.Tx,
+	wallets map[uuid.UUID]lockedWallet,
+) (map[int]string, error) {
+
+	var ids []int
+	for _, w := range wallets {
+		ids = append(ids, w.assetTypeID)
+	}
+
+	rows, err := tx.Query(ctx, `SELECT id, code FROM assets WHERE id = ANY($1)`, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	codes := make(map[int]string)
+	for rows.Next() {
+		var id int
+		var code string
+		if err := rows.Scan(&id, &code); err != nil {
+			return nil, err
+		}
+		codes[id] = code
+	}
+
+	return codes, rows.Err()
+}
+
+// ClaimLedgerEvents claims up to limit undelivered events that are due, oldest
+// first. A claimed event is not handed out again for lease, if it is neither
+// delivered nor failed by then it is claimed again, which makes delivery at
+// least once. Concurrent dispatchers claim disjoint events.
+func (r *Repository) ClaimLedgerEvents(
+	ctx context.Context,
+	limit int,
+	lease time.Duration,
+) ([]LedgerEvent, error) {
+
+	rows, err := r.pool.Query(ctx, `
+		UPDATE outbox_events
+		SET next_attempt_at = NOW() + $2 * INTERVAL '1 millisecond'
+		WHERE id IN (
+			SELECT id
+			FROM outbox_events
+			WHERE delivered_at IS NULL AND next_attempt_at <= NOW()
+			ORDER BY id
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, attempts, payload
+	`, limit, lease.Milliseconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []LedgerEvent{}
+
+	for rows.Next() {
+		var seq int64
+		var attempts int
+		var payload []byte
+		if err := rows.Scan(&seq, &attempts, &payload); err != nil {
+			return nil, err
+		}
+
+		var e LedgerEvent
+		if err := json.Unmarshal(payload, &e); err != nil {
+			return nil, err
+		}
+		e.Sequence, e.Attempts = seq, attempts
+		events = append(events, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// UPDATE ... RETURNING does not keep the subquery order
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].Sequence < events[j].Sequence
+	})
+
+	return events, nil
+}
+
+func (r *Repository) MarkLedgerEventDelivered(ctx context.Context, sequence int64) error {
+	_, err := r.pool.Exec(ctx, `
+		UPDATE outbox_events
+		SET delivered_at = NOW(), attempts = attempts + 1, last_error = NULL
+		WHERE id = $1
+	`, sequence)
+	return err
+}
+
+// MarkLedgerEventFailed records a failed delivery and schedules the next
+// attempt after retryIn.
+func (r *Repository) MarkLedgerEventFailed(
+	ctx context.Context,
+	sequence int64,
+	cause error,
+	retryIn time.Duration,
+) error {
+	_, err := r.pool.Exec(ctx, `
+		UPDATE outbox_events
+		SET attempts = attempts + 1,
+		    last_error = $2,
+		    next_attempt_at = NOW() + $3 * INTERVAL '1 millisecond'
+		WHERE id = $1
+	`, sequence, cause.Error(), retryIn.Milliseconds())
+	return err
+}
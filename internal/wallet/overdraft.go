@@ -0,0 +1,96 @@
+package wallet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// NegativeBalance is a wallet below zero. RemainingCredit is how much
+// further its overdraft limit lets it go.
+type NegativeBalance struct {
+	WalletID        uuid.UUID  `json:"wallet_id"`
+	UserID          *uuid.UUID `json:"user_id"`
+	Label           *string    `json:"label"`
+	Asset           string     `json:"asset"`
+	Balance         int64      `json:"balance"`
+	OverdraftLimit  int64      `json:"overdraft_limit"`
+	RemainingCredit int64      `json:"remaining_credit"`
+}
+
+// SetOverdraftLimit sets how far below zero a wallet may go. A limit lower
+// than the wallet's current debt fails with ErrOverdraftInUse.
+func (r *Repository) SetOverdraftLimit(
+	ctx context.Context,
+	walletID uuid.UUID,
+	limit int64,
+) (Balance, error) {
+
+	if limit < 0 {
+		return Balance{}, fmt.Errorf("%w: must not be negative", ErrInvalidOverdraft)
+	}
+
+	var allowNegative bool
+	err := r.pool.QueryRow(ctx, `
+		UPDATE wallets SET overdraft_limit = $2
+		WHERE id = $1 AND NOT allow_negative
+		RETURNING allow_negative
+	`, walletID, limit).Scan(&allowNegative)
+
+	var pgErr *pgconn.PgError
+	switch {
+	case errors.As(err, &pgErr) && pgErr.ConstraintName == "wallets_balance_check":
+		return Balance{}, fmt.Errorf("%w: wallet=%s limit=%d", ErrOverdraftInUse, walletID, limit)
+	case errors.Is(err, pgx.ErrNoRows):
+		b, err := r.GetWalletBalances(ctx, walletID)
+		if err != nil {
+			return Balance{}, err
+		}
+		return b, fmt.Errorf("%w: contra wallets have no limit", ErrInvalidOverdraft)
+	case err != nil:
+		return Balance{}, err
+	}
+
+	return r.GetWalletBalances(ctx, walletID)
+}
+
+// ListNegativeBalances returns the wallets below zero, optionally of one
+// asset, most negative first. Contra wallets, which are negative by
+// design, are left out.
+func (r *Repository) ListNegativeBalances(
+	ctx context.Context,
+	assetCode *string,
+) ([]NegativeBalance, error) {
+
+	rows, err := r.pool.Query(ctx, `
+		SELECT w.id, w.user_id, w.label, a.code, w.balance, w.overdraft_limit
+		FROM wallets w
+		JOIN assets a ON a.id = w.asset_type_id
+		WHERE w.balance < 0
+		  AND NOT w.allow_negative
+		  AND ($1::text IS NULL OR a.code = $1)
+		ORDER BY a.code, w.balance, w.id
+	`, assetCode)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	balances := []NegativeBalance{}
+
+	for rows.Next() {
+		var b NegativeBalance
+		err := rows.Scan(&b.WalletID, &b.UserID, &b.Label, &b.Asset, &b.Balance, &b.OverdraftLimit)
+		if err != nil {
+			return nil, err
+		}
+		b.RemainingCredit = b.Balance + b.OverdraftLimit
+		balances = append(balances, b)
+	}
+
+	return balances, rows.Err()
+}
@@ -0,0 +1,39 @@
+package wallet
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Cursor is the keyset position of the last row of a page. Listings are
+// ordered by (created_at, id) newest first, the next page starts strictly
+// after the cursor.
+type Cursor struct {
+	CreatedAt time.Time `json:"t"`
+	ID        uuid.UUID `json:"id"`
+}
+
+// Encode returns the opaque form handed out to clients as next_cursor.
+func (c Cursor) Encode() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func DecodeCursor(s string) (Cursor, error) {
+	var c Cursor
+
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+
+	if err := json.Unmarshal(b, &c); err != nil || c.ID == uuid.Nil {
+		return c, ErrInvalidCursor
+	}
+
+	return c, nil
+}
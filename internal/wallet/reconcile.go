@@ -0,0 +1,328 @@
+package wallet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+type WalletDrift struct {
+	WalletID      uuid.UUID `json:"wallet_id"`
+	Asset         string    `json:"asset"`
+	CachedBalance int64     `json:"cached_balance"`
+	LedgerBalance int64     `json:"ledger_balance"`
+	Drift         int64     `json:"drift"`
+
+	// Repaired is set once repair left the wallet without drift,
+	// RepairError says why it could not.
+	Repaired                bool       `json:"repaired"`
+	RepairError             string     `json:"repair_error,omitempty"`
+	AdjustmentTransactionID *uuid.UUID `json:"adjustment_transaction_id,omitempty"`
+}
+
+type AssetDrift struct {
+	Asset          string `json:"asset"`
+	DriftedWallets int    `json:"drifted_wallets"`
+	NetDrift       int64  `json:"net_drift"`
+	AbsoluteDrift  int64  `json:"absolute_drift"`
+}
+
+// ReconciliationReport lists the drifting wallets. Unrepaired counts those
+// still drifting, Repaired is only set by a repair that left none.
+type ReconciliationReport struct {
+	CheckedAt      time.Time     `json:"checked_at"`
+	WalletsChecked int           `json:"wallets_checked"`
+	Repaired       bool          `json:"repaired"`
+	Unrepaired     int           `json:"unrepaired_wallets"`
+	Wallets        []WalletDrift `json:"wallets"`
+	Assets         []AssetDrift  `json:"assets"`
+}
+
+// FindBalanceDrift recomputes every wallet balance from the ledger in a
+// single snapshot and returns the wallets whose cached balance differs,
+// along with the number of wallets checked.
+func (r *Repository) FindBalanceDrift(ctx context.Context) ([]WalletDrift, int, error) {
+
+	rows, err := r.pool.Query(ctx, `
+		SELECT w.id, a.code, w.balance,
+		       COALESCE(SUM(
+		           CASE WHEN e.direction = 'credit' THEN e.amount ELSE -e.amount END
+		       ), 0)
+		FROM wallets w
+		JOIN assets a ON a.id = w.asset_type_id
+		LEFT JOIN ledger_entries e ON e.wallet_id = w.id
+		GROUP BY w.id, a.code, w.balance
+		ORDER BY a.code, w.id
+	`)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	drifts := []WalletDrift{}
+	var checked int
+
+	for rows.Next() {
+		var d WalletDrift
+		if err := rows.Scan(&d.WalletID, &d.Asset, &d.CachedBalance, &d.LedgerBalance); err != nil {
+			return nil, 0, err
+		}
+		checked++
+
+		d.Drift = d.CachedBalance - d.LedgerBalance
+		if d.Drift != 0 {
+			drifts = append(drifts, d)
+		}
+	}
+
+	return drifts, checked, rows.Err()
+}
+
+// RepairBalanceDrift recomputes the cached balance of a wallet from its
+// ledger entries, which are the source of truth, and records the correction
+// as an adjustment transaction with actor and reason. Drift is recomputed
+// under the wallet lock, a wallet that no longer drifts is left alone. A
+// ledger balance the wallet may not hold, such as one below its overdraft
+// limit, is not repairable.
+func (r *Repository) RepairBalanceDrift(
+	ctx context.Context,
+	walletID uuid.UUID,
+	actor string,
+	reason string,
+) (WalletDrift, error) {
+
+	d := WalletDrift{WalletID: walletID}
+
+	if strings.TrimSpace(actor) == "" || strings.TrimSpace(reason) == "" {
+		return d, fmt.Errorf("%w: actor and reason are required", ErrInvalidRepair)
+	}
+
+	tx, err := r.pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return d, err
+	}
+	defer tx.Rollback(ctx)
+
+	var assetTypeID int
+	err = tx.QueryRow(ctx, `
+		SELECT a.code, w.asset_type_id, w.balance
+		FROM wallets w
+		JOIN assets a ON a.id = w.asset_type_id
+		WHERE w.id = $1
+		FOR UPDATE OF w
+	`, walletID).Scan(&d.Asset, &assetTypeID, &d.CachedBalance)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return d, fmt.Errorf("wallet with id %s not found: %w", walletID, ErrWalletNotFound)
+		}
+		return d, err
+	}
+
+	d.LedgerBalance, err = ledgerBalanceAt(ctx, tx, walletID, farFuture)
+	if err != nil {
+		return d, err
+	}
+
+	d.Drift = d.CachedBalance - d.LedgerBalance
+	if d.Drift == 0 {
+		return d, tx.Commit(ctx)
+	}
+
+	_, err = tx.Exec(ctx,
+		`UPDATE wallets SET balance = $1 WHERE id = $2`,
+		d.LedgerBalance,
+		walletID,
+	)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.ConstraintName == "wallets_balance_check" {
+		return d, fmt.Errorf("ledger balance %d is below what the wallet may hold: %w", d.LedgerBalance, ErrNotRepairable)
+	}
+	if err != nil {
+		return d, err
+	}
+
+	// the adjustment posts no entries, the ledger was right. The cached
+	// balance moved from the wallet when it was too high, to it when it
+	// was too low.
+
+	amount := d.Drift
+	from, to := &walletID, (*uuid.UUID)(nil)
+	if amount < 0 {
+		amount = -amount
+		from, to = nil, &walletID
+	}
+
+	txnID := uuid.New()
+	referenceID := fmt.Sprintf("repair:%s:%d", walletID, time.Now().UnixNano())
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO transactions
+			(id, reference_id, type, status, from_wallet_id, to_wallet_id, amount,
+			 reason, actor, repairs_wallet_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	`, txnID, referenceID, TxTypeAdjustment, StatusCompleted, from, to, amount, reason, actor, walletID)
+	if err != nil {
+		return d, err
+	}
+
+	err = insertLedgerEvent(ctx, tx, txnID, journal{
+		referenceID: referenceID,
+		txType:      TxTypeAdjustment,
+		legs:        []Posting{},
+	}, map[uuid.UUID]lockedWallet{
+		walletID: {assetTypeID: assetTypeID},
+	}, map[uuid.UUID]int64{
+		walletID: d.LedgerBalance,
+	})
+	if err != nil {
+		return d, err
+	}
+
+	d.AdjustmentTransactionID = &txnID
+
+	return d, tx.Commit(ctx)
+}
+
+// AdjustLedgerToBalance writes an adjustment transaction that brings the
+// ledger of a wallet in line with its cached balance, for drift that an
+// investigation found to be the ledger's fault. The counterparty is the
+// adjustment system wallet of the asset, so drift on the adjustment wallet
+// itself cannot be adjusted. The reason and actor are kept on the
+// transaction.
+func (r *Repository) AdjustLedgerToBalance(
+	ctx context.Context,
+	walletID uuid.UUID,
+	actor string,
+	reason string,
+) (WalletDrift, error) {
+
+	d := WalletDrift{WalletID: walletID}
+
+	tx, err := r.pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return d, err
+	}
+	defer tx.Rollback(ctx)
+
+	var assetTypeID int
+	err = tx.QueryRow(ctx, `
+		SELECT w.asset_type_id, a.code
+		FROM wallets w
+		JOIN assets a ON a.id = w.asset_type_id
+		WHERE w.id = $1
+	`, walletID).Scan(&assetTypeID, &d.Asset)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return d, fmt.Errorf("wallet with id %s not found: %w", walletID, ErrWalletNotFound)
+		}
+		return d, err
+	}
+
+	adjustmentID, err := ensureSystemWallet(ctx, tx, assetTypeID, RoleAdjustment)
+	if err != nil {
+		return d, err
+	}
+	if adjustmentID == walletID {
+		return d, fmt.Errorf("adjustment wallet cannot offset its own drift: %w", ErrNotRepairable)
+	}
+
+	// Lock wallets in deterministic order
+
+	ids := journalWalletIDs([]Posting{{WalletID: walletID}, {WalletID: adjustmentID}})
+	wallets, err := lockWallets(ctx, tx, ids)
+	if err != nil {
+		return d, err
+	}
+
+	d.CachedBalance = wallets[walletID].balance
+
+	d.LedgerBalance, err = ledgerBalanceAt(ctx, tx, walletID, farFuture)
+	if err != nil {
+		return d, err
+	}
+
+	d.Drift = d.CachedBalance - d.LedgerBalance
+	if d.Drift == 0 {
+		return d, tx.Commit(ctx)
+	}
+
+	amount := d.Drift
+	walletDirection, adjustmentDirection := DirectionCredit, DirectionDebit
+	if amount < 0 {
+		amount = -amount
+		walletDirection, adjustmentDirection = DirectionDebit, DirectionCredit
+	}
+
+	// Create adjustment transaction
+
+	txnID := uuid.New()
+	referenceID := fmt.Sprintf("adjustment:%s:%d", walletID, time.Now().UnixNano())
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO transactions (id, reference_id, type, status, reason, actor)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`, txnID, referenceID, TxTypeAdjustment, StatusCompleted, reason, actor)
+	if err != nil {
+		return d, err
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO ledger_entries
+			(id, transaction_id, wallet_id, direction, amount)
+		VALUES
+			($1, $2, $3, $4, $5),
+			($6, $2, $7, $8, $5)
+	`,
+		uuid.New(),
+		txnID,
+		walletID,
+		walletDirection,
+		amount,
+		uuid.New(),
+		adjustmentID,
+		adjustmentDirection,
+	)
+	if err != nil {
+		return d, err
+	}
+
+	// only the adjustment wallet's cached balance moves, the adjusted
+	// wallet's cached balance is what the entries catch up to
+
+	_, err = tx.Exec(ctx, `
+		UPDATE wallets
+		SET balance = balance - $1
+		WHERE id = $2
+	`, d.Drift, adjustmentID)
+	if err != nil {
+		return d, err
+	}
+
+	err = insertLedgerEvent(ctx, tx, txnID, journal{
+		referenceID: referenceID,
+		txType:      TxTypeAdjustment,
+		legs: []Posting{
+			{WalletID: walletID, Direction: walletDirection, Amount: amount},
+			{WalletID: adjustmentID, Direction: adjustmentDirection, Amount: amount},
+		},
+	}, wallets, map[uuid.UUID]int64{
+		walletID:     d.CachedBalance,
+		adjustmentID: wallets[adjustmentID].balance - d.Drift,
+	})
+	if err != nil {
+		return d, err
+	}
+
+	d.AdjustmentTransactionID = &txnID
+
+	return d, tx.Commit(ctx)
+}
+
+// farFuture bounds ledger sums that should include every entry.
+var farFuture = time.Date(9999, 1, 1, 0, 0, 0, 0, time.UTC)
@@ -0,0 +1,82 @@
+package wallet
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+type SystemWalletRole string
+
+const (
+	RoleTreasury   SystemWalletRole = "treasury"
+	RoleRevenue    SystemWalletRole = "revenue"
+	RoleAdjustment SystemWalletRole = "adjustment"
+	RoleIssuance   SystemWalletRole = "issuance"
+)
+
+// contra roles carry the negative side of postings without a real
+// counterparty, their wallets may go below zero
+var contraRoles = map[SystemWalletRole]bool{
+	RoleAdjustment: true,
+	RoleIssuance:   true,
+}
+
+// roles provisioned for every new asset
+var DefaultSystemWalletRoles = []SystemWalletRole{
+	RoleTreasury,
+	RoleRevenue,
+	RoleIssuance,
+}
+
+type systemWalletKey struct {
+	asset AssetCode
+	role  SystemWalletRole
+}
+
+// systemWalletRegistry caches system wallet ids resolved from the
+// system_wallets table. A system wallet never changes once provisioned,
+// so entries are kept for the life of the process.
+type systemWalletRegistry struct {
+	mu      sync.RWMutex
+	wallets map[systemWalletKey]uuid.UUID
+}
+
+func newSystemWalletRegistry() *systemWalletRegistry {
+	return &systemWalletRegistry{
+		wallets: make(map[systemWalletKey]uuid.UUID),
+	}
+}
+
+func (s *Service) systemWallet(
+	ctx context.Context,
+	asset AssetCode,
+	role SystemWalletRole,
+) (uuid.UUID, error) {
+
+	key := systemWalletKey{asset: asset, role: role}
+
+	s.registry.mu.RLock()
+	id, ok := s.registry.wallets[key]
+	s.registry.mu.RUnlock()
+	if ok {
+		return id, nil
+	}
+
+	id, err := s.repo.GetSystemWallet(ctx, string(asset), string(role))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return uuid.Nil, ErrUnsupportedAsset
+		}
+		return uuid.Nil, err
+	}
+
+	s.registry.mu.Lock()
+	s.registry.wallets[key] = id
+	s.registry.mu.Unlock()
+
+	return id, nil
+}
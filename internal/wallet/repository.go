@@ -4,9 +4,11 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
@@ -27,7 +29,7 @@ func (r *Repository) GetWalletBalance(ctx context.Context, walletId uuid.UUID) (
 
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows){
-			return 0, fmt.Errorf("wallet with id %s not found: %w", walletId, pgx.ErrNoRows)
+			return 0, fmt.Errorf("wallet with id %s not found: %w", walletId, ErrWalletNotFound)
     }
     return 0, fmt.Errorf("database query failed: %w", err)
 	}
@@ -49,179 +51,215 @@ func (r *Repository) GetWalletAssetCode(
         WHERE w.id = $1
     `, walletID).Scan(&code)
 
+    if errors.Is(err, pgx.ErrNoRows) {
+        return "", fmt.Errorf("wallet with id %s not found: %w", walletID, ErrWalletNotFound)
+    }
+
     return code, err
 }
 
+func (r *Repository) GetWalletUserID(
+    ctx context.Context,
+    walletID uuid.UUID,
+) (*uuid.UUID, error) {
+
+    var userID *uuid.UUID
+
+    err := r.pool.QueryRow(ctx, `
+        SELECT user_id
+        FROM wallets
+        WHERE id = $1
+    `, walletID).Scan(&userID)
+
+    if errors.Is(err, pgx.ErrNoRows) {
+        return nil, fmt.Errorf("wallet with id %s not found: %w", walletID, ErrWalletNotFound)
+    }
+
+    return userID, err
+}
+
+// Transfer moves amount from one wallet to another as a two legged journal.
 func (r *Repository) Transfer(
     ctx context.Context,
     referenceID string,
+    txType string,
     fromWalletID uuid.UUID,
     toWalletID uuid.UUID,
     amount int64,
 ) error {
 
-    if amount <= 0 {
-        return fmt.Errorf("amount must be positive")
-    }
-
-    tx, err := r.pool.BeginTx(ctx, pgx.TxOptions{})
-    if err != nil {
-        return err
-    }
-    defer tx.Rollback(ctx)
+    _, err := r.PostJournal(ctx, referenceID, txType, []Posting{
+        {WalletID: fromWalletID, Direction: DirectionDebit, Amount: amount},
+        {WalletID: toWalletID, Direction: DirectionCredit, Amount: amount},
+    })
 
+    return err
+}
 
-    // check if transaction already completed
+func (r *Repository) GetTransactionByReference(
+    ctx context.Context,
+    referenceID string,
+) (Transaction, error) {
 
-    var existingID uuid.UUID
-    err = tx.QueryRow(ctx,
-        `SELECT id FROM transactions WHERE reference_id = $1`,
-        referenceID,
-    ).Scan(&existingID)
+    var t Transaction
 
-    if err == nil {
-        return tx.Commit(ctx)
-    }
-    if !errors.Is(err, pgx.ErrNoRows) {
-        return err
-    }
+    err := r.pool.QueryRow(ctx, `
+        SELECT id, reference_id, type, status,
+               from_wallet_id, to_wallet_id, amount, created_at,
+               reverses_transaction_id, reversed_amount
+        FROM transactions
+        WHERE reference_id = $1
+    `, referenceID).Scan(
+        &t.ID,
+        &t.ReferenceID,
+        &t.Type,
+        &t.Status,
+        &t.FromWalletID,
+        &t.ToWalletID,
+        &t.Amount,
+        &t.CreatedAt,
+        &t.ReversesTransactionID,
+        &t.ReversedAmount,
+    )
 
+    return t, err
+}
 
-    // Lock wallets in deterministic order
+func (r *Repository) CreateUser(
+    ctx context.Context,
+    id uuid.UUID,
+    name string,
+) error {
+    _, err := r.pool.Exec(ctx, `
+        INSERT INTO users (id, name)
+        VALUES ($1, $2)
+    `, id, name)
 
-    var first, second uuid.UUID
-    if fromWalletID.String() < toWalletID.String() {
-        first, second = fromWalletID, toWalletID
-    } else {
-        first, second = toWalletID, fromWalletID
-    }
+    return err
+}
 
-    // lock first wallet
-    if _, err := tx.Exec(ctx,
-        `SELECT id FROM wallets WHERE id = $1 FOR UPDATE`,
-        first,
-    ); err != nil {
-        return err
-    }
+func (r *Repository) CreateAsset(
+    ctx context.Context,
+    code string,
+    roles []SystemWalletRole,
+) (int, error) {
 
-    // lock second wallet
-    if _, err := tx.Exec(ctx,
-        `SELECT id FROM wallets WHERE id = $1 FOR UPDATE`,
-        second,
-    ); err != nil {
-        return err
+    tx, err := r.pool.BeginTx(ctx, pgx.TxOptions{})
+    if err != nil {
+        return 0, err
     }
+    defer tx.Rollback(ctx)
 
+    var id int
 
-    // Check balance
-
-    var balance int64
-    err = tx.QueryRow(ctx,
-        `SELECT balance FROM wallets WHERE id = $1`,
-        fromWalletID,
-    ).Scan(&balance)
+    err = tx.QueryRow(ctx, `
+        INSERT INTO assets (code)
+        VALUES ($1)
+        RETURNING id
+    `, code).Scan(&id)
     if err != nil {
-        return err
+        if isUniqueViolation(err) {
+            return 0, ErrDuplicateAsset
+        }
+        return 0, err
     }
 
-    if balance < amount {
-        return fmt.Errorf("insufficient balance")
+    // provision the system wallets of the asset in the same transaction
+    for _, role := range roles {
+        if _, err := provisionSystemWallet(ctx, tx, id, code, role); err != nil {
+            return 0, err
+        }
     }
 
+    return id, tx.Commit(ctx)
+}
 
-    // Create transaction record
+func provisionSystemWallet(
+    ctx context.Context,
+    tx pgx.Tx,
+    assetTypeID int,
+    assetCode string,
+    role SystemWalletRole,
+) (uuid.UUID, error) {
 
-    txnID := uuid.New()
+    walletID := uuid.New()
+    label := strings.ToUpper(string(role[:1])) + string(role[1:]) + " " + assetCode
 
-    _, err = tx.Exec(ctx,
-        `INSERT INTO transactions (id, reference_id, type, status)
-         VALUES ($1, $2, 'transfer', 'completed')`,
-        txnID,
-        referenceID,
-    )
+    _, err := tx.Exec(ctx, `
+        INSERT INTO wallets (id, label, user_id, asset_type_id, balance, allow_negative)
+        VALUES ($1, $2, NULL, $3, 0, $4)
+    `, walletID, label, assetTypeID, contraRoles[role])
     if err != nil {
-        return err
+        return uuid.Nil, err
     }
 
-
-    // Insert ledger entries (double entry)
-
-    debitEntryID := uuid.New()
-    creditEntryID := uuid.New()
-
-    _, err = tx.Exec(ctx,
-        `INSERT INTO ledger_entries
-            (id, transaction_id, wallet_id, direction, amount)
-         VALUES
-            ($1, $2, $3, 'debit', $4),
-            ($5, $2, $6, 'credit', $4)`,
-        debitEntryID,
-        txnID,
-        fromWalletID,
-        amount,
-        creditEntryID,
-        toWalletID,
-    )
+    _, err = tx.Exec(ctx, `
+        INSERT INTO system_wallets (asset_type_id, role, wallet_id)
+        VALUES ($1, $2, $3)
+    `, assetTypeID, role, walletID)
     if err != nil {
-        return err
+        return uuid.Nil, err
     }
 
+    return walletID, nil
+}
 
-    // Update cached balances
+// ensureSystemWallet returns the system wallet of an asset for role,
+// provisioning it when the asset predates the role.
+func ensureSystemWallet(
+    ctx context.Context,
+    tx pgx.Tx,
+    assetTypeID int,
+    role SystemWalletRole,
+) (uuid.UUID, error) {
 
-    _, err = tx.Exec(ctx,
-        `UPDATE wallets
-         SET balance = balance - $1
-         WHERE id = $2`,
-        amount,
-        fromWalletID,
+    var (
+        walletID uuid.UUID
+        code     string
     )
-    if err != nil {
-        return err
-    }
 
-    _, err = tx.Exec(ctx,
-        `UPDATE wallets
-         SET balance = balance + $1
-         WHERE id = $2`,
-        amount,
-        toWalletID,
-    )
+    // the asset row lock serializes concurrent provisioning of the same role
+    err := tx.QueryRow(ctx,
+        `SELECT code FROM assets WHERE id = $1 FOR UPDATE`,
+        assetTypeID,
+    ).Scan(&code)
     if err != nil {
-        return err
+        if errors.Is(err, pgx.ErrNoRows) {
+            return uuid.Nil, ErrAssetNotFound
+        }
+        return uuid.Nil, err
     }
 
+    err = tx.QueryRow(ctx, `
+        SELECT wallet_id FROM system_wallets
+        WHERE asset_type_id = $1 AND role = $2
+    `, assetTypeID, role).Scan(&walletID)
+    if err == nil {
+        return walletID, nil
+    }
+    if !errors.Is(err, pgx.ErrNoRows) {
+        return uuid.Nil, err
+    }
 
-    return tx.Commit(ctx)
-}
-
-func (r *Repository) CreateUser(
-    ctx context.Context,
-    id uuid.UUID,
-    name string,
-) error {
-    _, err := r.pool.Exec(ctx, `
-        INSERT INTO users (id, name)
-        VALUES ($1, $2)
-    `, id, name)
-
-    return err
+    return provisionSystemWallet(ctx, tx, assetTypeID, code, role)
 }
 
-func (r *Repository) CreateAsset(
+func (r *Repository) GetSystemWallet(
     ctx context.Context,
-    code string,
-) (int, error) {
+    assetCode string,
+    role string,
+) (uuid.UUID, error) {
 
-    var id int
+    var walletID uuid.UUID
 
     err := r.pool.QueryRow(ctx, `
-        INSERT INTO assets (code)
-        VALUES ($1)
-        RETURNING id
-    `, code).Scan(&id)
+        SELECT sw.wallet_id
+        FROM system_wallets sw
+        JOIN assets a ON a.id = sw.asset_type_id
+        WHERE a.code = $1 AND sw.role = $2
+    `, assetCode, role).Scan(&walletID)
 
-    return id, err
+    return walletID, err
 }
 
 func (r *Repository) CreateWallet(
@@ -232,43 +270,101 @@ func (r *Repository) CreateWallet(
     assetTypeID int,
 ) error {
 
-    _, err := r.pool.Exec(ctx, `
+    tx, err := r.pool.BeginTx(ctx, pgx.TxOptions{})
+    if err != nil {
+        return err
+    }
+    defer tx.Rollback(ctx)
+
+    // a wallet of a frozen user starts frozen, the share lock waits out a
+    // user freeze in progress
+    status, blockCredits := WalletStatusActive, false
+
+    if userID != nil {
+        err := tx.QueryRow(ctx,
+            `SELECT status, block_credits FROM users WHERE id = $1 FOR SHARE`,
+            *userID,
+        ).Scan(&status, &blockCredits)
+        if errors.Is(err, pgx.ErrNoRows) {
+            return ErrUserNotFound
+        }
+        if err != nil {
+            return err
+        }
+    }
+
+    _, err = tx.Exec(ctx, `
         INSERT INTO wallets (
             id,
             label,
             user_id,
             asset_type_id,
-            balance
+            balance,
+            status,
+            block_credits
         )
-        VALUES ($1, $2, $3, $4, 0)
+        VALUES ($1, $2, $3, $4, 0, $5, $6)
     `,
         id,
         label,
         userID,
         assetTypeID,
+        status,
+        blockCredits,
     )
+    if err == nil {
+        return tx.Commit(ctx)
+    }
+
+    var pgErr *pgconn.PgError
+    if errors.As(err, &pgErr) {
+        switch {
+        case pgErr.Code == "23505":
+            return ErrDuplicateWallet
+        case pgErr.Code == "23503" && pgErr.ConstraintName == "wallets_user_id_fkey":
+            return ErrUserNotFound
+        case pgErr.Code == "23503" && pgErr.ConstraintName == "wallets_asset_type_id_fkey":
+            return ErrAssetNotFound
+        }
+    }
 
     return err
 }
 
+func isUniqueViolation(err error) bool {
+    var pgErr *pgconn.PgError
+    return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
+
+// ListTransactions returns up to limit transactions matching filter newest
+// first, starting after cursor when it is set. The returned cursor is nil on
+// the last page.
 func (r *Repository) ListTransactions(
 	ctx context.Context,
+	filter ListFilter,
 	limit int,
-	offset int,
-) ([]Transaction, error) {
+	cursor *Cursor,
+) ([]Transaction, *Cursor, error) {
+
+	var b whereBuilder
+	b.addTransactionFilter(filter)
+	b.addCursor("t", cursor)
+	limitArg := b.arg(limit + 1)
 
 	rows, err := r.pool.Query(ctx, `
-		SELECT id, reference_id, type, status, created_at
-		FROM transactions
-		ORDER BY created_at DESC
-		LIMIT $1 OFFSET $2
-	`, limit, offset)
+		SELECT t.id, t.reference_id, t.type, t.status,
+		       t.from_wallet_id, t.to_wallet_id, t.amount, t.created_at,
+		       t.reverses_transaction_id, t.reversed_amount
+		FROM transactions t
+		`+b.sql()+`
+		ORDER BY t.created_at DESC, t.id DESC
+		LIMIT `+limitArg, b.args...)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	defer rows.Close()
 
-	var txs []Transaction
+	txs := []Transaction{}
 
 	for rows.Next() {
 		var t Transaction
@@ -277,34 +373,63 @@ func (r *Repository) ListTransactions(
 			&t.ReferenceID,
 			&t.Type,
 			&t.Status,
+			&t.FromWalletID,
+			&t.ToWalletID,
+			&t.Amount,
 			&t.CreatedAt,
+			&t.ReversesTransactionID,
+			&t.ReversedAmount,
 		); err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 		txs = append(txs, t)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	if len(txs) <= limit {
+		return txs, nil, nil
+	}
+
+	txs = txs[:limit]
+	last := txs[limit-1]
 
-	return txs, rows.Err()
+	return txs, &Cursor{CreatedAt: last.CreatedAt, ID: last.ID}, nil
 }
 
+// ListLedgerEntries returns up to limit ledger entries matching filter
+// newest first, starting after cursor when it is set. The returned cursor is
+// nil on the last page.
 func (r *Repository) ListLedgerEntries(
 	ctx context.Context,
+	filter ListFilter,
 	limit int,
-	offset int,
-) ([]LedgerEntry, error) {
+	cursor *Cursor,
+) ([]LedgerEntry, *Cursor, error) {
+
+	var b whereBuilder
+	b.addEntryConditions(filter)
+	b.addTransactionConditions(filter)
+	b.addCreatedRange("e", filter)
+	b.addCursor("e", cursor)
+	limitArg := b.arg(limit + 1)
 
 	rows, err := r.pool.Query(ctx, `
-		SELECT id, transaction_id, wallet_id, direction, amount, created_at
-		FROM ledger_entries
-		ORDER BY created_at DESC
-		LIMIT $1 OFFSET $2
-	`, limit, offset)
+		SELECT e.id, e.transaction_id, e.wallet_id, e.direction, e.amount, e.created_at
+		FROM ledger_entries e
+		JOIN transactions t ON t.id = e.transaction_id
+		JOIN wallets w ON w.id = e.wallet_id
+		JOIN assets a ON a.id = w.asset_type_id
+		`+b.sql()+`
+		ORDER BY e.created_at DESC, e.id DESC
+		LIMIT `+limitArg, b.args...)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	defer rows.Close()
 
-	var entries []LedgerEntry
+	entries := []LedgerEntry{}
 
 	for rows.Next() {
 		var e LedgerEntry
@@ -316,10 +441,20 @@ func (r *Repository) ListLedgerEntries(
 			&e.Amount,
 			&e.CreatedAt,
 		); err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 		entries = append(entries, e)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
 
-	return entries, rows.Err()
-}
\ No newline at end of file
+	if len(entries) <= limit {
+		return entries, nil, nil
+	}
+
+	entries = entries[:limit]
+	last := entries[limit-1]
+
+	return entries, &Cursor{CreatedAt: last.CreatedAt, ID: last.ID}, nil
+}
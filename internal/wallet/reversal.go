@@ -0,0 +1,223 @@
+package wallet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// ReverseTransaction posts a compensating transaction with mirrored ledger
+// entries for transactionID and links it to the original. A nil amount
+// reverses whatever has not been reversed yet. Partial amounts are only
+// supported for two legged transactions.
+func (r *Repository) ReverseTransaction(
+	ctx context.Context,
+	referenceID string,
+	transactionID uuid.UUID,
+	amount *int64,
+) (uuid.UUID, error) {
+
+	if amount != nil && *amount <= 0 {
+		return uuid.Nil, ErrInvalidAmount
+	}
+
+	tx, err := r.pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return uuid.Nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	// check if reversal already completed
+
+	var (
+		existingID        uuid.UUID
+		existingType      string
+		existingReverses  *uuid.UUID
+		existingRemainder bool
+		existingAmount    int64
+	)
+	err = tx.QueryRow(ctx, `
+		SELECT t.id, t.type, t.reverses_transaction_id, t.reverses_remainder,
+		       COALESCE((SELECT SUM(e.amount) FROM ledger_entries e
+		                 WHERE e.transaction_id = t.id AND e.direction = 'debit'), 0)
+		FROM transactions t
+		WHERE t.reference_id = $1
+	`, referenceID).Scan(&existingID, &existingType, &existingReverses, &existingRemainder, &existingAmount)
+
+	// a replay must repeat the request, an amount where there was none or
+	// none where there was one is a different request
+	if err == nil {
+		if existingType != TxTypeReversal ||
+			existingReverses == nil || *existingReverses != transactionID ||
+			existingRemainder != (amount == nil) ||
+			(amount != nil && *amount != existingAmount) {
+			return uuid.Nil, ErrIdempotencyConflict
+		}
+		return existingID, tx.Commit(ctx)
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return uuid.Nil, err
+	}
+
+	// lock the original so concurrent reversals see each other's amounts
+
+	var (
+		originalType   string
+		reversedAmount int64
+	)
+	err = tx.QueryRow(ctx, `
+		SELECT type, reversed_amount
+		FROM transactions
+		WHERE id = $1
+		FOR UPDATE
+	`, transactionID).Scan(&originalType, &reversedAmount)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return uuid.Nil, fmt.Errorf("transaction with id %s not found: %w", transactionID, ErrTransactionNotFound)
+		}
+		return uuid.Nil, err
+	}
+
+	if originalType == TxTypeReversal {
+		return uuid.Nil, fmt.Errorf("transaction is itself a reversal: %w", ErrNotReversible)
+	}
+	if originalType == TxTypeMint || originalType == TxTypeBurn {
+		return uuid.Nil, fmt.Errorf("supply changes are corrected with a burn or mint: %w", ErrNotReversible)
+	}
+
+	original, err := transactionLegs(ctx, tx, transactionID)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if len(original) == 0 {
+		return uuid.Nil, fmt.Errorf("transaction has no ledger entries: %w", ErrNotReversible)
+	}
+
+	var gross int64
+	for _, leg := range original {
+		if leg.Direction == DirectionDebit {
+			gross += leg.Amount
+		}
+	}
+
+	remaining := gross - reversedAmount
+	if remaining <= 0 {
+		return uuid.Nil, fmt.Errorf("transaction already fully reversed: %w", ErrNotReversible)
+	}
+
+	reverseAmount := remaining
+	if amount != nil {
+		reverseAmount = *amount
+	}
+	if reverseAmount > remaining {
+		return uuid.Nil, fmt.Errorf(
+			"requested=%d remaining=%d: %w",
+			reverseAmount,
+			remaining,
+			ErrReversalExceedsRemaining,
+		)
+	}
+
+	// mirror the original legs, scaled down for a partial reversal
+
+	fromWalletID, toWalletID, _ := transferSummary(original)
+	isTransfer := fromWalletID != nil
+
+	var legs []Posting
+	switch {
+	case reverseAmount == gross:
+		for _, leg := range original {
+			legs = append(legs, Posting{
+				WalletID:  leg.WalletID,
+				Direction: oppositeDirection(leg.Direction),
+				Amount:    leg.Amount,
+			})
+		}
+	case isTransfer:
+		legs = []Posting{
+			{WalletID: *toWalletID, Direction: DirectionDebit, Amount: reverseAmount},
+			{WalletID: *fromWalletID, Direction: DirectionCredit, Amount: reverseAmount},
+		}
+	default:
+		return uuid.Nil, ErrPartialReversalUnsupported
+	}
+
+	txnID, err := postJournal(ctx, tx, journal{
+		referenceID: referenceID,
+		txType:      TxTypeReversal,
+		legs:        legs,
+		reversesID:  &transactionID,
+	})
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	if amount == nil {
+		_, err = tx.Exec(ctx,
+			`UPDATE transactions SET reverses_remainder = TRUE WHERE id = $1`,
+			txnID,
+		)
+		if err != nil {
+			return uuid.Nil, err
+		}
+	}
+
+	// mark the original as reversed
+
+	status := StatusPartiallyReversed
+	if reversedAmount+reverseAmount == gross {
+		status = StatusReversed
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE transactions
+		SET reversed_amount = reversed_amount + $1,
+		    status = $2
+		WHERE id = $3
+	`, reverseAmount, status, transactionID)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	return txnID, tx.Commit(ctx)
+}
+
+func transactionLegs(
+	ctx context.Context,
+	tx pgx.Tx,
+	transactionID uuid.UUID,
+) ([]Posting, error) {
+
+	rows, err := tx.Query(ctx, `
+		SELECT wallet_id, direction, amount
+		FROM ledger_entries
+		WHERE transaction_id = $1
+		ORDER BY direction, wallet_id
+	`, transactionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var legs []Posting
+
+	for rows.Next() {
+		var p Posting
+		if err := rows.Scan(&p.WalletID, &p.Direction, &p.Amount); err != nil {
+			return nil, err
+		}
+		legs = append(legs, p)
+	}
+
+	return legs, rows.Err()
+}
+
+func oppositeDirection(direction string) string {
+	if direction == DirectionDebit {
+		return DirectionCredit
+	}
+	return DirectionDebit
+}
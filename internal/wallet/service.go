@@ -8,11 +8,14 @@ import (
 	"strings"
 
 	"github.com/google/uuid"
+    "github.com/jackc/pgx/v5"
     "github.com/jackc/pgx/v5/pgconn"
+    "wallet-service/internal/auth"
 )
 
 type Service struct{
-	repo *Repository
+	repo     *Repository
+	registry *systemWalletRegistry
 }
 
 type AssetCode string
@@ -22,61 +25,90 @@ const (
     AssetDiamond AssetCode = "DIAMOND"
 )
 
-var TreasuryWalletByAsset = map[AssetCode]uuid.UUID{
-    AssetGold:    uuid.MustParse("00000000-0000-0000-0000-000000000000"),
-    AssetDiamond: uuid.MustParse("00000000-0000-0000-0000-000000000001"),
-}
+const (
+    TxTypeTopup    = "topup"
+    TxTypeBonus    = "bonus"
+    TxTypeSpend    = "spend"
+    TxTypeTransfer = "transfer"
+    TxTypeSweep    = "sweep"
+    TxTypeReversal = "reversal"
+    TxTypeCapture    = "capture"
+    TxTypeAdjustment = "adjustment"
+    TxTypeClosure    = "closure"
+    TxTypeMint       = "mint"
+    TxTypeBurn       = "burn"
+)
 
-var RevenueWalletByAsset = map[AssetCode]uuid.UUID{
-    AssetGold:    uuid.MustParse("00000000-0000-0000-0000-000000000003"),
-    AssetDiamond: uuid.MustParse("00000000-0000-0000-0000-000000000002"),
-}
+const (
+    DefaultHoldTTL = 15 * time.Minute
+    MaxHoldTTL     = 7 * 24 * time.Hour
+)
 
-func NewService(repo *Repository) *Service{
-	return &Service{repo: repo}
-}
+const (
+    StatusCompleted         = "completed"
+    StatusReversed          = "reversed"
+    StatusPartiallyReversed = "partially_reversed"
+)
 
-func (s *Service) GetBalance(ctx context.Context, walletId uuid.UUID) (int64, error){
-	return s.repo.GetWalletBalance(ctx,walletId)
+func NewService(repo *Repository) *Service{
+	return &Service{
+		repo:     repo,
+		registry: newSystemWalletRegistry(),
+	}
 }
 
-func (s *Service) TopUpUserWallet(
+// transferWithRetry runs a transfer and retries it when postgres aborts it
+// as a deadlock victim.
+func (s *Service) transferWithRetry(
     ctx context.Context,
     referenceID string,
-    userWalletID uuid.UUID,
-    asset AssetCode,
+    txType string,
+    fromWalletID uuid.UUID,
+    toWalletID uuid.UUID,
     amount int64,
 ) error {
 
-    treasuryID, ok := TreasuryWalletByAsset[asset]
-    if !ok {
-        return errors.New("unsupported asset type")
-    }
+    return withRetry(txType, func() error {
+        return s.repo.Transfer(
+            ctx,
+            referenceID,
+            txType,
+            fromWalletID,
+            toWalletID,
+            amount,
+        )
+    })
+}
 
-	    walletAsset, err := s.repo.GetWalletAssetCode(ctx, userWalletID)
-    if err != nil {
+// PostJournal posts a multi leg journal with the same retry policy as
+// transfers.
+func (s *Service) PostJournal(
+    ctx context.Context,
+    referenceID string,
+    txType string,
+    legs []Posting,
+) (uuid.UUID, error) {
+
+    var txnID uuid.UUID
+
+    err := withRetry(txType, func() error {
+        var err error
+        txnID, err = s.repo.PostJournal(ctx, referenceID, txType, legs)
         return err
-    }
+    })
 
-    if walletAsset != string(asset) {
-        return fmt.Errorf(
-            "wallet asset mismatch: wallet=%s request=%s",
-            walletAsset,
-            asset,
-        )
-    }
+    return txnID, err
+}
+
+// withRetry retries fn when postgres aborts it as a deadlock victim or when
+// a concurrent request with the same reference_id won the insert.
+func withRetry(op string, fn func() error) error {
 
     const maxRetries = 3
 
     for i := 0; i < maxRetries; i++ {
 
-        err := s.repo.Transfer(
-            ctx,
-            referenceID,
-            treasuryID,
-            userWalletID,
-            amount,
-        )
+        err := fn()
 
         if err == nil {
             return nil
@@ -88,10 +120,91 @@ func (s *Service) TopUpUserWallet(
             continue
         }
 
+        // retrying resolves a lost reference_id race through the replay check
+        if errors.As(err, &pgErr) && pgErr.Code == "23505" &&
+            (pgErr.ConstraintName == "transactions_reference_id_key" ||
+                pgErr.ConstraintName == "holds_reference_id_key") {
+            if i == maxRetries-1 {
+                return ErrDuplicateReference
+            }
+            continue
+        }
+
         return err
     }
 
-    return errors.New("topup failed after retries")
+    return fmt.Errorf("%s: %w", op, ErrRetriesExhausted)
+}
+
+func (s *Service) checkWalletAsset(
+    ctx context.Context,
+    walletID uuid.UUID,
+    asset AssetCode,
+) error {
+
+    walletAsset, err := s.repo.GetWalletAssetCode(ctx, walletID)
+    if err != nil {
+        return err
+    }
+
+    if walletAsset != string(asset) {
+        return &AssetMismatchError{
+            WalletID:       walletID,
+            WalletAsset:    AssetCode(walletAsset),
+            RequestedAsset: asset,
+        }
+    }
+
+    return nil
+}
+
+func (s *Service) GetBalance(ctx context.Context, walletId uuid.UUID) (Balance, error){
+	return s.repo.GetWalletBalances(ctx,walletId)
+}
+
+func (s *Service) GetBalanceAsOf(
+    ctx context.Context,
+    walletID uuid.UUID,
+    asOf time.Time,
+) (HistoricalBalance, error) {
+    return s.repo.GetWalletBalanceAsOf(ctx, walletID, asOf.UTC())
+}
+
+func (s *Service) GetAssetBalancesAsOf(
+    ctx context.Context,
+    asset AssetCode,
+    asOf time.Time,
+    limit int,
+    afterWalletID *uuid.UUID,
+) ([]HistoricalBalance, error) {
+    return s.repo.ListAssetBalancesAsOf(ctx, strings.ToUpper(string(asset)), asOf.UTC(), limit, afterWalletID)
+}
+
+func (s *Service) TopUpUserWallet(
+    ctx context.Context,
+    referenceID string,
+    userWalletID uuid.UUID,
+    asset AssetCode,
+    amount int64,
+) error {
+
+    treasuryID, err := s.systemWallet(ctx, asset, RoleTreasury)
+    if err != nil {
+        return err
+    }
+
+    if err := s.checkWalletAsset(ctx, userWalletID, asset); err != nil {
+        return err
+    }
+
+    return s.transferWithRetry(
+        ctx,
+        referenceID,
+        TxTypeTopup,
+        treasuryID,
+        userWalletID,
+        amount,
+    )
 }
 
 func (s *Service) GrantBonus(
@@ -102,105 +215,400 @@ func (s *Service) GrantBonus(
     amount int64,
 ) error {
 
-    treasuryID, ok := TreasuryWalletByAsset[asset]
-    if !ok {
-        return errors.New("unsupported asset type")
+    treasuryID, err := s.systemWallet(ctx, asset, RoleTreasury)
+    if err != nil {
+        return err
     }
 
     // asset validation
-    walletAsset, err := s.repo.GetWalletAssetCode(ctx, userWalletID)
+    if err := s.checkWalletAsset(ctx, userWalletID, asset); err != nil {
+        return err
+    }
+
+    return s.transferWithRetry(
+        ctx,
+        referenceID,
+        TxTypeBonus,
+        treasuryID,
+        userWalletID,
+        amount,
+    )
+}
+
+func (s *Service) SpendFromWallet(
+    ctx context.Context,
+    referenceID string,
+    userWalletID uuid.UUID,
+    asset AssetCode,
+    amount int64,
+) error {
+
+    revenueID, err := s.systemWallet(ctx, asset, RoleRevenue)
     if err != nil {
         return err
     }
 
-    if walletAsset != string(asset) {
-        return fmt.Errorf(
-            "wallet asset mismatch: wallet=%s request=%s",
-            walletAsset,
-            asset,
-        )
+    if err := s.checkWalletAsset(ctx, userWalletID, asset); err != nil {
+        return err
     }
 
-    const maxRetries = 3
+    // spent value is earned revenue, it goes back to treasury only through a sweep
+    return s.transferWithRetry(
+        ctx,
+        referenceID,
+        TxTypeSpend,
+        userWalletID,
+        revenueID,
+        amount,
+    )
+}
 
-    for i := 0; i < maxRetries; i++ {
+// SweepRevenue moves the whole revenue wallet balance of an asset back to
+// its treasury wallet and returns the swept amount.
+func (s *Service) SweepRevenue(
+    ctx context.Context,
+    referenceID string,
+    asset AssetCode,
+) (int64, error) {
 
-        err := s.repo.Transfer(
-            ctx,
-            referenceID,
-            treasuryID,
-            userWalletID,
-            amount,
-        )
+    treasuryID, err := s.systemWallet(ctx, asset, RoleTreasury)
+    if err != nil {
+        return 0, err
+    }
 
-        if err == nil {
-            return nil
-        }
+    revenueID, err := s.systemWallet(ctx, asset, RoleRevenue)
+    if err != nil {
+        return 0, err
+    }
 
-        var pgErr *pgconn.PgError
-        if errors.As(err, &pgErr) && pgErr.Code == "40P01" {
-            time.Sleep(50 * time.Millisecond)
-            continue
+    // a replayed sweep returns the amount of the original one
+    existing, err := s.repo.GetTransactionByReference(ctx, referenceID)
+    if err == nil {
+        if existing.Type != TxTypeSweep ||
+            existing.FromWalletID == nil || *existing.FromWalletID != revenueID ||
+            existing.ToWalletID == nil || *existing.ToWalletID != treasuryID ||
+            existing.Amount == nil {
+            return 0, ErrIdempotencyConflict
         }
+        return *existing.Amount, nil
+    }
+    if !errors.Is(err, pgx.ErrNoRows) {
+        return 0, err
+    }
 
-        return err
+    amount, err := s.repo.GetWalletBalance(ctx, revenueID)
+    if err != nil {
+        return 0, err
     }
 
-    return errors.New("bonus grant failed after retries")
+    if amount == 0 {
+        return 0, ErrNothingToSweep
+    }
+
+    err = s.transferWithRetry(
+        ctx,
+        referenceID,
+        TxTypeSweep,
+        revenueID,
+        treasuryID,
+        amount,
+    )
+    if err != nil {
+        return 0, err
+    }
+
+    return amount, nil
 }
 
-func (s *Service) SpendFromWallet(
+func (s *Service) TransferBetweenUsers(
     ctx context.Context,
     referenceID string,
-    userWalletID uuid.UUID,
+    fromWalletID uuid.UUID,
+    toWalletID uuid.UUID,
     asset AssetCode,
     amount int64,
 ) error {
 
-    treasuryID, ok := TreasuryWalletByAsset[asset]
-    if !ok {
-        return errors.New("unsupported asset type")
+    if fromWalletID == toWalletID {
+        return ErrSameWallet
+    }
+
+    // both sides must be user owned wallets holding the requested asset
+    for _, walletID := range []uuid.UUID{fromWalletID, toWalletID} {
+        userID, err := s.repo.GetWalletUserID(ctx, walletID)
+        if err != nil {
+            return err
+        }
+        if userID == nil {
+            return ErrNotUserWallet
+        }
+
+        if err := s.checkWalletAsset(ctx, walletID, asset); err != nil {
+            return err
+        }
+    }
+
+    return s.transferWithRetry(
+        ctx,
+        referenceID,
+        TxTypeTransfer,
+        fromWalletID,
+        toWalletID,
+        amount,
+    )
+}
+
+// ReserveHold reserves amount on a user wallet for a two step purchase. A
+// zero ttl uses DefaultHoldTTL.
+func (s *Service) ReserveHold(
+    ctx context.Context,
+    referenceID string,
+    walletID uuid.UUID,
+    asset AssetCode,
+    amount int64,
+    ttl time.Duration,
+) (Hold, error) {
+
+    if ttl == 0 {
+        ttl = DefaultHoldTTL
+    }
+    if ttl < 0 || ttl > MaxHoldTTL {
+        return Hold{}, fmt.Errorf("hold ttl must be between 0 and %s: %w", MaxHoldTTL, ErrInvalidHoldTTL)
+    }
+
+    if err := s.checkWalletAsset(ctx, walletID, asset); err != nil {
+        return Hold{}, err
     }
 
-    walletAsset, err := s.repo.GetWalletAssetCode(ctx, userWalletID)
+    var hold Hold
+
+    err := withRetry("reserve", func() error {
+        var err error
+        hold, err = s.repo.ReserveHold(ctx, referenceID, walletID, amount, time.Now().UTC().Add(ttl))
+        return err
+    })
+
+    return hold, err
+}
+
+// CaptureHold captures a hold, crediting the revenue wallet of its asset
+// like a spend. A nil amount captures the full hold.
+func (s *Service) CaptureHold(
+    ctx context.Context,
+    referenceID string,
+    holdID uuid.UUID,
+    amount *int64,
+) (uuid.UUID, error) {
+
+    hold, err := s.repo.GetHold(ctx, holdID)
+    if err != nil {
+        return uuid.Nil, err
+    }
+
+    walletAsset, err := s.repo.GetWalletAssetCode(ctx, hold.WalletID)
+    if err != nil {
+        return uuid.Nil, err
+    }
+
+    revenueID, err := s.systemWallet(ctx, AssetCode(walletAsset), RoleRevenue)
     if err != nil {
+        return uuid.Nil, err
+    }
+
+    var txnID uuid.UUID
+
+    err = withRetry(TxTypeCapture, func() error {
+        var err error
+        txnID, err = s.repo.CaptureHold(ctx, referenceID, holdID, amount, revenueID)
         return err
+    })
+
+    return txnID, err
+}
+
+func (s *Service) VoidHold(ctx context.Context, holdID uuid.UUID) (Hold, error) {
+    return s.repo.VoidHold(ctx, holdID)
+}
+
+func (s *Service) GetHold(ctx context.Context, holdID uuid.UUID) (Hold, error) {
+    return s.repo.GetHold(ctx, holdID)
+}
+
+func (s *Service) ReverseTransaction(
+    ctx context.Context,
+    referenceID string,
+    transactionID uuid.UUID,
+    amount *int64,
+) (uuid.UUID, error) {
+
+    var reversalID uuid.UUID
+
+    err := withRetry(TxTypeReversal, func() error {
+        var err error
+        reversalID, err = s.repo.ReverseTransaction(ctx, referenceID, transactionID, amount)
+        return err
+    })
+
+    return reversalID, err
+}
+
+func (s *Service) GetStatement(
+    ctx context.Context,
+    walletID uuid.UUID,
+    from time.Time,
+    to time.Time,
+    limit int,
+) (Statement, error) {
+    return s.repo.GetStatement(ctx, walletID, from.UTC(), to.UTC(), limit)
+}
+
+// Reconcile compares every cached wallet balance with the balance derived
+// from the ledger.
+func (s *Service) Reconcile(ctx context.Context) (ReconciliationReport, error) {
+
+    report := ReconciliationReport{CheckedAt: time.Now().UTC()}
+
+    drifts, checked, err := s.repo.FindBalanceDrift(ctx)
+    if err != nil {
+        return report, err
     }
 
-    if walletAsset != string(asset) {
-        return fmt.Errorf(
-            "wallet asset mismatch: wallet=%s request=%s",
-            walletAsset,
-            asset,
-        )
+    report.WalletsChecked = checked
+    report.Unrepaired = len(drifts)
+    report.Wallets = drifts
+    report.Assets = summarizeDrift(drifts)
+
+    return report, nil
+}
+
+// RepairDrift reconciles and recomputes the cached balance of each drifting
+// wallet from the ledger, recording every correction as an adjustment made
+// by actor for reason. Wallets that cannot be repaired stay drifting and
+// are marked in the report.
+func (s *Service) RepairDrift(
+    ctx context.Context,
+    actor string,
+    reason string,
+) (ReconciliationReport, error) {
+
+    if strings.TrimSpace(actor) == "" || strings.TrimSpace(reason) == "" {
+        return ReconciliationReport{}, fmt.Errorf("%w: actor and reason are required", ErrInvalidRepair)
     }
 
-    const maxRetries = 3
+    report, err := s.Reconcile(ctx)
+    if err != nil {
+        return report, err
+    }
 
-    for i := 0; i < maxRetries; i++ {
+    report.Unrepaired = 0
 
-        err := s.repo.Transfer(
-            ctx,
-            referenceID,
-            userWalletID,
-            treasuryID,
-            amount,
-        )
+    for i, d := range report.Wallets {
+        repaired, err := s.repo.RepairBalanceDrift(ctx, d.WalletID, actor, reason)
+        if errors.Is(err, ErrNotRepairable) {
+            report.Wallets[i].RepairError = err.Error()
+            report.Unrepaired++
+            continue
+        }
+        if err != nil {
+            return report, fmt.Errorf("repair wallet %s: %w", d.WalletID, err)
+        }
+        repaired.Repaired = true
+        report.Wallets[i] = repaired
+    }
 
-        if err == nil {
-            return nil
+    report.Repaired = report.Unrepaired == 0
+
+    return report, nil
+}
+
+// SnapshotBalances takes the end of day balance snapshots of every complete
+// day from from, or from the first day without snapshots when from is nil,
+// and returns the number of days and snapshots written.
+func (s *Service) SnapshotBalances(ctx context.Context, from *time.Time) (int, int64, error) {
+
+    start := time.Time{}
+    if from != nil {
+        start = utcDay(*from)
+    } else {
+        day, ok, err := s.repo.FirstUnsnapshottedDay(ctx)
+        if err != nil || !ok {
+            return 0, 0, err
         }
+        start = day
+    }
 
-        var pgErr *pgconn.PgError
-        if errors.As(err, &pgErr) && pgErr.Code == "40P01" {
-            time.Sleep(50 * time.Millisecond)
+    var days int
+    var written int64
+
+    last := lastSnapshotDay(time.Now())
+    for day := start; !day.After(last); day = day.AddDate(0, 0, 1) {
+        n, err := s.repo.SnapshotDay(ctx, day)
+        if err != nil {
+            return days, written, fmt.Errorf("snapshot %s: %w", day.Format(dateLayout), err)
+        }
+        days++
+        written += n
+    }
+
+    return days, written, nil
+}
+
+func (s *Service) GetBalanceHistory(
+    ctx context.Context,
+    walletID uuid.UUID,
+    from time.Time,
+    to time.Time,
+    interval string,
+) (BalanceHistory, error) {
+
+    if interval != "day" {
+        return BalanceHistory{}, fmt.Errorf("%w: %q, only day is supported", ErrUnsupportedInterval, interval)
+    }
+
+    from, to = utcDay(from), utcDay(to)
+    if from.After(to) {
+        return BalanceHistory{}, fmt.Errorf("%w: from is after to", ErrInvalidHistoryRange)
+    }
+    if to.Sub(from) >= maxHistoryDays*24*time.Hour {
+        return BalanceHistory{}, fmt.Errorf("%w: at most %d days", ErrInvalidHistoryRange, maxHistoryDays)
+    }
+
+    return s.repo.GetBalanceHistory(ctx, walletID, from, to)
+}
+
+func (s *Service) CheckInvariants(ctx context.Context) (InvariantReport, error) {
+    return s.repo.CheckInvariants(ctx)
+}
+
+func summarizeDrift(drifts []WalletDrift) []AssetDrift {
+    byAsset := make(map[string]*AssetDrift)
+    assets := []AssetDrift{}
+    var order []string
+
+    for _, d := range drifts {
+        if d.Drift == 0 {
             continue
         }
+        a, ok := byAsset[d.Asset]
+        if !ok {
+            a = &AssetDrift{Asset: d.Asset}
+            byAsset[d.Asset] = a
+            order = append(order, d.Asset)
+        }
+        a.DriftedWallets++
+        a.NetDrift += d.Drift
+        if d.Drift < 0 {
+            a.AbsoluteDrift -= d.Drift
+        } else {
+            a.AbsoluteDrift += d.Drift
+        }
+    }
 
-        return err
+    for _, code := range order {
+        assets = append(assets, *byAsset[code])
     }
 
-    return errors.New("spend failed after retries")
+    return assets
 }
 
 func (s *Service) CreateUser(
@@ -225,7 +633,7 @@ func (s *Service) CreateAsset(
 
     code = strings.ToUpper(strings.TrimSpace(code))
 
-    return s.repo.CreateAsset(ctx, code)
+    return s.repo.CreateAsset(ctx, code, DefaultSystemWalletRoles)
 }
 
 func (s *Service) CreateWallet(
@@ -253,16 +661,314 @@ func (s *Service) CreateWallet(
 
 func (s *Service) GetTransactions(
 	ctx context.Context,
+	filter ListFilter,
 	limit int,
-	offset int,
-) ([]Transaction, error) {
-	return s.repo.ListTransactions(ctx, limit, offset)
+	cursor *Cursor,
+) ([]Transaction, *Cursor, error) {
+
+	filter.normalize()
+
+	if err := filter.Validate(); err != nil {
+		return nil, nil, err
+	}
+	if err := s.repo.checkFilterAsset(ctx, filter); err != nil {
+		return nil, nil, err
+	}
+
+	return s.repo.ListTransactions(ctx, filter, limit, cursor)
 }
 
 func (s *Service) GetLedgerEntries(
 	ctx context.Context,
+	filter ListFilter,
 	limit int,
-	offset int,
-) ([]LedgerEntry, error) {
-	return s.repo.ListLedgerEntries(ctx, limit, offset)
-}
\ No newline at end of file
+	cursor *Cursor,
+) ([]LedgerEntry, *Cursor, error) {
+
+	filter.normalize()
+
+	if err := filter.Validate(); err != nil {
+		return nil, nil, err
+	}
+	if err := s.repo.checkFilterAsset(ctx, filter); err != nil {
+		return nil, nil, err
+	}
+
+	return s.repo.ListLedgerEntries(ctx, filter, limit, cursor)
+}
+
+func (s *Service) CreateWebhook(
+    ctx context.Context,
+    url string,
+    eventTypes []string,
+) (WebhookSubscription, error) {
+    return s.repo.CreateWebhook(ctx, url, eventTypes)
+}
+
+func (s *Service) ListWebhooks(ctx context.Context) ([]WebhookSubscription, error) {
+    return s.repo.ListWebhooks(ctx)
+}
+
+func (s *Service) DeleteWebhook(ctx context.Context, id uuid.UUID) error {
+    return s.repo.DeleteWebhook(ctx, id)
+}
+
+func (s *Service) ListWebhookDeliveries(
+    ctx context.Context,
+    subscriptionID uuid.UUID,
+    status *string,
+    limit int,
+) ([]WebhookDelivery, error) {
+    return s.repo.ListWebhookDeliveries(ctx, subscriptionID, status, limit)
+}
+
+func (s *Service) GetWebhookDelivery(ctx context.Context, id uuid.UUID) (WebhookDelivery, error) {
+    return s.repo.GetWebhookDelivery(ctx, id)
+}
+
+func (s *Service) ReplayWebhookDelivery(ctx context.Context, id uuid.UUID) (WebhookDelivery, error) {
+    return s.repo.ReplayWebhookDelivery(ctx, id)
+}
+
+func (s *Service) GetWalletStreamStart(ctx context.Context, walletID uuid.UUID) (WalletStreamStart, error) {
+    return s.repo.GetWalletStreamStart(ctx, walletID)
+}
+
+func (s *Service) ListWalletEvents(
+    ctx context.Context,
+    walletID uuid.UUID,
+    after int64,
+    limit int,
+) ([]WalletEvent, error) {
+    return s.repo.ListWalletEvents(ctx, walletID, after, limit)
+}
+
+func (s *Service) IssueAPIKey(ctx context.Context, name string, scopes []string) (APIKey, error) {
+    return s.repo.IssueAPIKey(ctx, name, scopes)
+}
+
+func (s *Service) AuthenticateAPIKey(ctx context.Context, key string) (APIKey, error) {
+    return s.repo.AuthenticateAPIKey(ctx, key)
+}
+
+func (s *Service) ListAPIKeys(ctx context.Context) ([]APIKey, error) {
+    return s.repo.ListAPIKeys(ctx)
+}
+
+func (s *Service) RevokeAPIKey(ctx context.Context, id uuid.UUID) (APIKey, error) {
+    return s.repo.RevokeAPIKey(ctx, id)
+}
+
+// AuthorizeWallet rejects end-user requests for a wallet the user does not
+// own. Requests without an end-user subject, made with an API key, may use
+// any wallet.
+func (s *Service) AuthorizeWallet(ctx context.Context, walletID uuid.UUID) error {
+
+    subject, ok := auth.SubjectFrom(ctx)
+    if !ok {
+        return nil
+    }
+
+    owner, err := s.repo.GetWalletUserID(ctx, walletID)
+    if err != nil {
+        return err
+    }
+    if owner == nil || *owner != subject {
+        return fmt.Errorf("wallet %s: %w", walletID, ErrWalletForbidden)
+    }
+
+    return nil
+}
+
+func (s *Service) FreezeWallet(
+    ctx context.Context,
+    walletID uuid.UUID,
+    blockCredits bool,
+    actor string,
+    reason string,
+) (WalletState, error) {
+    return s.repo.FreezeWallet(ctx, walletID, blockCredits, actor, reason)
+}
+
+func (s *Service) UnfreezeWallet(
+    ctx context.Context,
+    walletID uuid.UUID,
+    actor string,
+    reason string,
+) (WalletState, error) {
+    return s.repo.UnfreezeWallet(ctx, walletID, actor, reason)
+}
+
+func (s *Service) FreezeUser(
+    ctx context.Context,
+    userID uuid.UUID,
+    blockCredits bool,
+    actor string,
+    reason string,
+) ([]WalletState, error) {
+    return s.repo.FreezeUserWallets(ctx, userID, blockCredits, actor, reason)
+}
+
+func (s *Service) UnfreezeUser(
+    ctx context.Context,
+    userID uuid.UUID,
+    actor string,
+    reason string,
+) ([]WalletState, error) {
+    return s.repo.UnfreezeUserWallets(ctx, userID, actor, reason)
+}
+
+// CloseWallet closes a wallet. With sweep set, a remaining balance is moved
+// to the treasury wallet of its asset, otherwise the balance must be zero.
+func (s *Service) CloseWallet(
+    ctx context.Context,
+    walletID uuid.UUID,
+    sweep bool,
+    actor string,
+    reason string,
+) (WalletState, error) {
+
+    var sweepTo *uuid.UUID
+
+    if sweep {
+        asset, err := s.repo.GetWalletAssetCode(ctx, walletID)
+        if err != nil {
+            return WalletState{}, err
+        }
+
+        treasuryID, err := s.systemWallet(ctx, AssetCode(asset), RoleTreasury)
+        if err != nil {
+            return WalletState{}, err
+        }
+        sweepTo = &treasuryID
+    }
+
+    return s.repo.CloseWallet(ctx, walletID, sweepTo, actor, reason)
+}
+
+func (s *Service) GetWalletStatusHistory(
+    ctx context.Context,
+    walletID uuid.UUID,
+) ([]WalletStatusChange, error) {
+    return s.repo.GetWalletStatusHistory(ctx, walletID)
+}
+
+func (s *Service) SetVelocityLimit(
+    ctx context.Context,
+    in VelocityLimitInput,
+) (VelocityLimit, error) {
+    in.Asset = strings.ToUpper(in.Asset)
+    return s.repo.SetVelocityLimit(ctx, in)
+}
+
+func (s *Service) ListVelocityLimits(
+    ctx context.Context,
+    assetCode *string,
+    walletID *uuid.UUID,
+) ([]VelocityLimit, error) {
+    if assetCode != nil {
+        code := strings.ToUpper(*assetCode)
+        assetCode = &code
+    }
+    return s.repo.ListVelocityLimits(ctx, assetCode, walletID)
+}
+
+func (s *Service) DeleteVelocityLimit(ctx context.Context, id uuid.UUID) error {
+    return s.repo.DeleteVelocityLimit(ctx, id)
+}
+
+func (s *Service) GetWalletLimits(
+    ctx context.Context,
+    walletID uuid.UUID,
+) ([]LimitUsage, error) {
+    return s.repo.GetWalletLimits(ctx, walletID)
+}
+
+func (s *Service) SetOverdraftLimit(
+    ctx context.Context,
+    walletID uuid.UUID,
+    limit int64,
+) (Balance, error) {
+    return s.repo.SetOverdraftLimit(ctx, walletID, limit)
+}
+
+func (s *Service) ListNegativeBalances(
+    ctx context.Context,
+    assetCode *string,
+) ([]NegativeBalance, error) {
+    if assetCode != nil {
+        code := strings.ToUpper(*assetCode)
+        assetCode = &code
+    }
+    return s.repo.ListNegativeBalances(ctx, assetCode)
+}
+
+// MintSupply issues amount of an asset into its treasury wallet.
+func (s *Service) MintSupply(
+    ctx context.Context,
+    referenceID string,
+    asset AssetCode,
+    amount int64,
+) (uuid.UUID, error) {
+    return s.changeSupply(ctx, referenceID, asset, TxTypeMint, amount)
+}
+
+// BurnSupply retires amount of an asset from its treasury wallet.
+func (s *Service) BurnSupply(
+    ctx context.Context,
+    referenceID string,
+    asset AssetCode,
+    amount int64,
+) (uuid.UUID, error) {
+    return s.changeSupply(ctx, referenceID, asset, TxTypeBurn, amount)
+}
+
+func (s *Service) changeSupply(
+    ctx context.Context,
+    referenceID string,
+    asset AssetCode,
+    txType string,
+    amount int64,
+) (uuid.UUID, error) {
+
+    var txnID uuid.UUID
+
+    err := withRetry(txType, func() error {
+        var err error
+        txnID, err = s.repo.ChangeSupply(ctx, referenceID, strings.ToUpper(string(asset)), txType, amount)
+        return err
+    })
+
+    return txnID, err
+}
+
+func (s *Service) SetMaxSupply(
+    ctx context.Context,
+    asset AssetCode,
+    maxSupply *int64,
+) (Supply, error) {
+
+    code := strings.ToUpper(string(asset))
+
+    if err := s.repo.SetMaxSupply(ctx, code, maxSupply); err != nil {
+        return Supply{}, err
+    }
+
+    return s.repo.GetSupply(ctx, code)
+}
+
+func (s *Service) GetSupply(ctx context.Context, asset AssetCode) (Supply, error) {
+    return s.repo.GetSupply(ctx, strings.ToUpper(string(asset)))
+}
+
+// AdjustLedgerToBalance posts an adjustment that moves the ledger of one
+// wallet to its cached balance. Unlike repair it trusts the cache, so it
+// needs a reason.
+func (s *Service) AdjustLedgerToBalance(
+    ctx context.Context,
+    walletID uuid.UUID,
+    actor string,
+    reason string,
+) (WalletDrift, error) {
+    return s.repo.AdjustLedgerToBalance(ctx, walletID, actor, reason)
+}
@@ -0,0 +1,226 @@
+package wallet
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// snapshotGrace keeps the snapshot of a day from being taken right after
+// midnight, while transactions stamped before midnight may still commit.
+const snapshotGrace = 5 * time.Minute
+
+// maxHistoryDays bounds a balance history request.
+const maxHistoryDays = 1000
+
+type BalancePoint struct {
+	Date     string `json:"date"`
+	Balance  int64  `json:"balance"`
+	Snapshot bool   `json:"snapshot"`
+}
+
+type BalanceHistory struct {
+	WalletID uuid.UUID      `json:"wallet_id"`
+	Asset    string         `json:"asset"`
+	Interval string         `json:"interval"`
+	From     string         `json:"from"`
+	To       string         `json:"to"`
+	Points   []BalancePoint `json:"points"`
+}
+
+const dateLayout = "2006-01-02"
+
+// utcDay truncates t to the start of its UTC day.
+func utcDay(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// lastSnapshotDay is the latest day whose snapshot can be taken at now.
+func lastSnapshotDay(now time.Time) time.Time {
+	return utcDay(now.Add(-snapshotGrace)).AddDate(0, 0, -1)
+}
+
+// SnapshotDay records the end of day balance of every wallet that existed by
+// the end of day. A wallet with a snapshot of the previous day adds that
+// day's entries to it, any other wallet sums its whole ledger. Existing
+// snapshots are kept, so taking a day twice is a no-op. It returns the
+// number of snapshots written.
+func (r *Repository) SnapshotDay(ctx context.Context, day time.Time) (int64, error) {
+
+	start := utcDay(day)
+	end := start.AddDate(0, 0, 1)
+
+	tag, err := r.pool.Exec(ctx, `
+		INSERT INTO wallet_balance_snapshots (wallet_id, day, balance)
+		SELECT w.id, $1::date,
+		       CASE
+		           WHEN p.wallet_id IS NOT NULL THEN p.balance + (
+		               SELECT COALESCE(SUM(
+		                   CASE WHEN e.direction = 'credit' THEN e.amount ELSE -e.amount END
+		               ), 0)
+		               FROM ledger_entries e
+		               WHERE e.wallet_id = w.id
+		                 AND e.created_at >= $1
+		                 AND e.created_at < $2
+		           )
+		           ELSE (
+		               SELECT COALESCE(SUM(
+		                   CASE WHEN e.direction = 'credit' THEN e.amount ELSE -e.amount END
+		               ), 0)
+		               FROM ledger_entries e
+		               WHERE e.wallet_id = w.id
+		                 AND e.created_at < $2
+		           )
+		       END
+		FROM wallets w
+		LEFT JOIN wallet_balance_snapshots p
+		       ON p.wallet_id = w.id AND p.day = $1::date - 1
+		WHERE w.created_at < $2
+		ON CONFLICT (wallet_id, day) DO NOTHING
+	`, start, end)
+	if err != nil {
+		return 0, err
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+// FirstUnsnapshottedDay returns the day after the latest snapshot, or the
+// day of the first ledger entry when there are no snapshots yet. ok is
+// false when the ledger is empty.
+func (r *Repository) FirstUnsnapshottedDay(ctx context.Context) (time.Time, bool, error) {
+
+	var day *time.Time
+
+	err := r.pool.QueryRow(ctx, `
+		SELECT COALESCE(
+			(SELECT MAX(day) + 1 FROM wallet_balance_snapshots),
+			(SELECT MIN(created_at)::date FROM ledger_entries)
+		)
+	`).Scan(&day)
+	if err != nil || day == nil {
+		return time.Time{}, false, err
+	}
+
+	return utcDay(*day), true, nil
+}
+
+// GetBalanceHistory returns the end of day balance of a wallet for every day
+// in [from, to]. Days with a snapshot use it, the other days, such as the
+// current one, are derived from the ledger starting at the nearest earlier
+// point, so only entries after the last snapshot are summed.
+func (r *Repository) GetBalanceHistory(
+	ctx context.Context,
+	walletID uuid.UUID,
+	from time.Time,
+	to time.Time,
+) (BalanceHistory, error) {
+
+	from, to = utcDay(from), utcDay(to)
+
+	h := BalanceHistory{
+		WalletID: walletID,
+		Interval: "day",
+		From:     from.Format(dateLayout),
+		To:       to.Format(dateLayout),
+		Points:   []BalancePoint{},
+	}
+
+	asset, err := r.GetWalletAssetCode(ctx, walletID)
+	if err != nil {
+		return h, err
+	}
+	h.Asset = asset
+
+	// snapshots from the day before the range, to open it
+
+	rows, err := r.pool.Query(ctx, `
+		SELECT day, balance
+		FROM wallet_balance_snapshots
+		WHERE wallet_id = $1 AND day >= $2::date - 1 AND day <= $3::date
+	`, walletID, from, to)
+	if err != nil {
+		return h, err
+	}
+
+	snapshots := make(map[time.Time]int64)
+	for rows.Next() {
+		var day time.Time
+		var balance int64
+		if err := rows.Scan(&day, &balance); err != nil {
+			rows.Close()
+			return h, err
+		}
+		snapshots[utcDay(day)] = balance
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return h, err
+	}
+
+	// the first day without a snapshot is where ledger derivation starts
+
+	firstMissing := to.AddDate(0, 0, 1)
+	for d := from; !d.After(to); d = d.AddDate(0, 0, 1) {
+		if _, ok := snapshots[d]; !ok {
+			firstMissing = d
+			break
+		}
+	}
+
+	var running int64
+	deltas := make(map[time.Time]int64)
+
+	if !firstMissing.After(to) {
+		if prev, ok := snapshots[firstMissing.AddDate(0, 0, -1)]; ok {
+			running = prev
+		} else {
+			running, err = ledgerBalanceAt(ctx, r.pool, walletID, firstMissing)
+			if err != nil {
+				return h, err
+			}
+		}
+
+		rows, err := r.pool.Query(ctx, `
+			SELECT created_at::date,
+			       SUM(CASE WHEN direction = 'credit' THEN amount ELSE -amount END)
+			FROM ledger_entries
+			WHERE wallet_id = $1 AND created_at >= $2 AND created_at < $3
+			GROUP BY created_at::date
+		`, walletID, firstMissing, to.AddDate(0, 0, 1))
+		if err != nil {
+			return h, err
+		}
+		for rows.Next() {
+			var day time.Time
+			var delta int64
+			if err := rows.Scan(&day, &delta); err != nil {
+				rows.Close()
+				return h, err
+			}
+			deltas[utcDay(day)] = delta
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return h, err
+		}
+	}
+
+	for d := from; !d.After(to); d = d.AddDate(0, 0, 1) {
+		p := BalancePoint{Date: d.Format(dateLayout)}
+
+		if balance, ok := snapshots[d]; ok {
+			p.Balance, p.Snapshot = balance, true
+			running = balance
+		} else {
+			running += deltas[d]
+			p.Balance = running
+		}
+
+		h.Points = append(h.Points, p)
+	}
+
+	return h, nil
+}
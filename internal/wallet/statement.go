@@ -0,0 +1,122 @@
+package wallet
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ledgerBalanceAt derives a wallet balance from its ledger entries created
+// strictly before at.
+func ledgerBalanceAt(
+	ctx context.Context,
+	q querier,
+	walletID uuid.UUID,
+	at time.Time,
+) (int64, error) {
+
+	var balance int64
+
+	err := q.QueryRow(ctx, `
+		SELECT COALESCE(SUM(
+			CASE WHEN direction = 'credit' THEN amount ELSE -amount END
+		), 0)
+		FROM ledger_entries
+		WHERE wallet_id = $1 AND created_at < $2
+	`, walletID, at).Scan(&balance)
+
+	return balance, err
+}
+
+// GetStatement returns the ledger entries of a wallet created in [from, to),
+// oldest first, with the running balance after each entry. At most limit
+// entries are returned, HasMore reports whether the range holds more.
+func (r *Repository) GetStatement(
+	ctx context.Context,
+	walletID uuid.UUID,
+	from time.Time,
+	to time.Time,
+	limit int,
+) (Statement, error) {
+
+	st := Statement{
+		WalletID: walletID,
+		From:     from,
+		To:       to,
+		Entries:  []StatementEntry{},
+	}
+
+	asset, err := r.GetWalletAssetCode(ctx, walletID)
+	if err != nil {
+		return st, err
+	}
+	st.Asset = asset
+
+	st.OpeningBalance, err = ledgerBalanceAt(ctx, r.pool, walletID, from)
+	if err != nil {
+		return st, err
+	}
+
+	st.ClosingBalance, err = ledgerBalanceAt(ctx, r.pool, walletID, to)
+	if err != nil {
+		return st, err
+	}
+
+	rows, err := r.pool.Query(ctx, `
+		SELECT e.id, e.transaction_id, t.reference_id, t.type,
+		       e.direction, e.amount,
+		       ARRAY(
+		           SELECT DISTINCT o.wallet_id
+		           FROM ledger_entries o
+		           WHERE o.transaction_id = e.transaction_id
+		             AND o.wallet_id <> e.wallet_id
+		       ),
+		       e.created_at
+		FROM ledger_entries e
+		JOIN transactions t ON t.id = e.transaction_id
+		WHERE e.wallet_id = $1
+		  AND e.created_at >= $2
+		  AND e.created_at < $3
+		ORDER BY e.created_at, e.id
+		LIMIT $4
+	`, walletID, from, to, limit+1)
+	if err != nil {
+		return st, err
+	}
+	defer rows.Close()
+
+	running := st.OpeningBalance
+
+	for rows.Next() {
+		var e StatementEntry
+		if err := rows.Scan(
+			&e.ID,
+			&e.TransactionID,
+			&e.ReferenceID,
+			&e.Type,
+			&e.Direction,
+			&e.Amount,
+			&e.CounterpartyWalletIDs,
+			&e.CreatedAt,
+		); err != nil {
+			return st, err
+		}
+
+		if len(st.Entries) == limit {
+			st.HasMore = true
+			break
+		}
+
+		if e.Direction == DirectionCredit {
+			running += e.Amount
+		} else {
+			running -= e.Amount
+		}
+		e.RunningBalance = running
+
+		st.Entries = append(st.Entries, e)
+	}
+
+	return st, rows.Err()
+}
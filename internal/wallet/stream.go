@@ -0,0 +1,147 @@
+package wallet
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// WalletEvent is a ledger event as seen by one wallet: its own legs and its
+// balance right after the transaction. Sequence is the outbox sequence of
+// the event. Every journal locks the wallets it touches before writing its
+// event, so the sequences of one wallet's events grow in commit order and a
+// stream can resume after the last sequence it saw.
+type WalletEvent struct {
+	Sequence        int64     `json:"sequence"`
+	EventID         uuid.UUID `json:"event_id"`
+	Type            string    `json:"type"`
+	TransactionID   uuid.UUID `json:"transaction_id"`
+	TransactionType string    `json:"transaction_type"`
+	ReferenceID     string    `json:"reference_id"`
+	Entries         []Posting `json:"entries"`
+	Balance         int64     `json:"balance"`
+	Asset           string    `json:"asset"`
+	OccurredAt      time.Time `json:"occurred_at"`
+}
+
+// WalletStreamStart is where a new event stream of a wallet starts: the
+// balance and the sequence of the last event behind it.
+type WalletStreamStart struct {
+	WalletID uuid.UUID `json:"wallet_id"`
+	Asset    string    `json:"asset"`
+	Balance  int64     `json:"balance"`
+	Sequence int64     `json:"sequence"`
+}
+
+func newWalletEvent(walletID uuid.UUID, e LedgerEvent) WalletEvent {
+	we := WalletEvent{
+		Sequence:        e.Sequence,
+		EventID:         e.ID,
+		Type:            e.Type,
+		TransactionID:   e.TransactionID,
+		TransactionType: e.TransactionType,
+		ReferenceID:     e.ReferenceID,
+		Entries:         []Posting{},
+		OccurredAt:      e.OccurredAt,
+	}
+
+	for _, leg := range e.Legs {
+		if leg.WalletID == walletID {
+			we.Entries = append(we.Entries, leg)
+		}
+	}
+	for _, b := range e.Balances {
+		if b.WalletID == walletID {
+			we.Balance, we.Asset = b.Balance, b.Asset
+		}
+	}
+
+	return we
+}
+
+// ListWalletEvents returns up to limit events of a wallet with a sequence
+// after after, oldest first.
+func (r *Repository) ListWalletEvents(
+	ctx context.Context,
+	walletID uuid.UUID,
+	after int64,
+	limit int,
+) ([]WalletEvent, error) {
+
+	rows, err := r.pool.Query(ctx, `
+		SELECT e.id, e.payload
+		FROM outbox_event_wallets w
+		JOIN outbox_events e ON e.id = w.event_sequence
+		WHERE w.wallet_id = $1 AND w.event_sequence > $2
+		ORDER BY w.event_sequence
+		LIMIT $3
+	`, walletID, after, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []WalletEvent{}
+
+	for rows.Next() {
+		var seq int64
+		var payload []byte
+		if err := rows.Scan(&seq, &payload); err != nil {
+			return nil, err
+		}
+
+		var e LedgerEvent
+		if err := json.Unmarshal(payload, &e); err != nil {
+			return nil, err
+		}
+		e.Sequence = seq
+
+		events = append(events, newWalletEvent(walletID, e))
+	}
+
+	return events, rows.Err()
+}
+
+// GetWalletStreamStart reads the balance of a wallet and the sequence of its
+// latest event in one snapshot.
+func (r *Repository) GetWalletStreamStart(
+	ctx context.Context,
+	walletID uuid.UUID,
+) (WalletStreamStart, error) {
+
+	s := WalletStreamStart{WalletID: walletID}
+
+	tx, err := r.pool.BeginTx(ctx, pgx.TxOptions{
+		IsoLevel:   pgx.RepeatableRead,
+		AccessMode: pgx.ReadOnly,
+	})
+	if err != nil {
+		return s, err
+	}
+	defer tx.Rollback(ctx)
+
+	err = tx.QueryRow(ctx, `
+		SELECT a.code, w.balance,
+		       COALESCE((
+		           SELECT MAX(event_sequence)
+		           FROM outbox_event_wallets
+		           WHERE wallet_id = w.id
+		       ), 0)
+		FROM wallets w
+		JOIN assets a ON a.id = w.asset_type_id
+		WHERE w.id = $1
+	`, walletID).Scan(&s.Asset, &s.Balance, &s.Sequence)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return s, fmt.Errorf("wallet with id %s not found: %w", walletID, ErrWalletNotFound)
+		}
+		return s, err
+	}
+
+	return s, tx.Commit(ctx)
+}
@@ -0,0 +1,241 @@
+package wallet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// Supply accounts for every unit of an asset. Issued is what was minted
+// less what was burned, and the issuance wallet holds its negative, so
+// Issued equals Treasury + Revenue + Adjustment + Circulating unless cached
+// balances drifted from the ledger.
+type Supply struct {
+	Asset       string `json:"asset"`
+	MaxSupply   *int64 `json:"max_supply"`
+	Minted      int64  `json:"minted"`
+	Burned      int64  `json:"burned"`
+	Issued      int64  `json:"issued"`
+	Treasury    int64  `json:"treasury"`
+	Revenue     int64  `json:"revenue"`
+	Adjustment  int64  `json:"adjustment"`
+	Circulating int64  `json:"circulating"`
+	Balanced    bool   `json:"balanced"`
+}
+
+// lockAsset takes the asset row lock, which serializes mints and burns of
+// the asset, and returns its id and max supply.
+func lockAsset(ctx context.Context, tx pgx.Tx, assetCode string) (int, *int64, error) {
+	var (
+		id        int
+		maxSupply *int64
+	)
+	err := tx.QueryRow(ctx,
+		`SELECT id, max_supply FROM assets WHERE code = $1 FOR UPDATE`,
+		assetCode,
+	).Scan(&id, &maxSupply)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, nil, ErrAssetNotFound
+	}
+	return id, maxSupply, err
+}
+
+// issuedSupply is the negated balance of the issuance wallet of an asset,
+// zero when nothing was ever minted.
+func issuedSupply(ctx context.Context, q querier, assetTypeID int) (int64, error) {
+	var issued int64
+	err := q.QueryRow(ctx, `
+		SELECT COALESCE(-SUM(w.balance), 0)
+		FROM system_wallets s
+		JOIN wallets w ON w.id = s.wallet_id
+		WHERE s.asset_type_id = $1 AND s.role = $2
+	`, assetTypeID, RoleIssuance).Scan(&issued)
+	return issued, err
+}
+
+// ChangeSupply mints amount from the issuance wallet of an asset into its
+// treasury, or burns it from the treasury back into the issuance wallet. A
+// mint may not take the issued supply past the asset's max supply.
+func (r *Repository) ChangeSupply(
+	ctx context.Context,
+	referenceID string,
+	assetCode string,
+	txType string,
+	amount int64,
+) (uuid.UUID, error) {
+
+	if amount <= 0 {
+		return uuid.Nil, ErrInvalidAmount
+	}
+
+	tx, err := r.pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return uuid.Nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	assetTypeID, maxSupply, err := lockAsset(ctx, tx, assetCode)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	issuanceID, err := ensureSystemWallet(ctx, tx, assetTypeID, RoleIssuance)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	treasuryID, err := ensureSystemWallet(ctx, tx, assetTypeID, RoleTreasury)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	from, to := issuanceID, treasuryID
+	if txType == TxTypeBurn {
+		from, to = treasuryID, issuanceID
+	}
+
+	// a replayed mint was already counted in the issued supply
+	if txType == TxTypeMint && maxSupply != nil {
+		var replayed bool
+		err := tx.QueryRow(ctx,
+			`SELECT EXISTS (SELECT 1 FROM transactions WHERE reference_id = $1)`,
+			referenceID,
+		).Scan(&replayed)
+		if err != nil {
+			return uuid.Nil, err
+		}
+
+		issued, err := issuedSupply(ctx, tx, assetTypeID)
+		if err != nil {
+			return uuid.Nil, err
+		}
+
+		if !replayed && issued+amount > *maxSupply {
+			return uuid.Nil, &SupplyCapError{
+				Asset:     AssetCode(assetCode),
+				MaxSupply: *maxSupply,
+				Issued:    issued,
+				Requested: amount,
+			}
+		}
+	}
+
+	txnID, err := postJournal(ctx, tx, journal{
+		referenceID: referenceID,
+		txType:      txType,
+		legs: []Posting{
+			{WalletID: from, Direction: DirectionDebit, Amount: amount},
+			{WalletID: to, Direction: DirectionCredit, Amount: amount},
+		},
+	})
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	return txnID, tx.Commit(ctx)
+}
+
+// SetMaxSupply caps the issued supply of an asset, nil removes the cap. The
+// cap cannot be lower than what is already issued.
+func (r *Repository) SetMaxSupply(
+	ctx context.Context,
+	assetCode string,
+	maxSupply *int64,
+) error {
+
+	if maxSupply != nil && *maxSupply <= 0 {
+		return fmt.Errorf("%w: must be positive", ErrInvalidMaxSupply)
+	}
+
+	tx, err := r.pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	assetTypeID, _, err := lockAsset(ctx, tx, assetCode)
+	if err != nil {
+		return err
+	}
+
+	issued, err := issuedSupply(ctx, tx, assetTypeID)
+	if err != nil {
+		return err
+	}
+
+	if maxSupply != nil && *maxSupply < issued {
+		return fmt.Errorf("%w: %d already issued", ErrInvalidMaxSupply, issued)
+	}
+
+	_, err = tx.Exec(ctx,
+		`UPDATE assets SET max_supply = $2 WHERE id = $1`,
+		assetTypeID,
+		maxSupply,
+	)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// GetSupply reads the supply of an asset in one snapshot.
+func (r *Repository) GetSupply(ctx context.Context, assetCode string) (Supply, error) {
+
+	s := Supply{Asset: assetCode}
+
+	tx, err := r.pool.BeginTx(ctx, pgx.TxOptions{
+		IsoLevel:   pgx.RepeatableRead,
+		AccessMode: pgx.ReadOnly,
+	})
+	if err != nil {
+		return s, err
+	}
+	defer tx.Rollback(ctx)
+
+	var assetTypeID int
+	err = tx.QueryRow(ctx,
+		`SELECT id, max_supply FROM assets WHERE code = $1`,
+		assetCode,
+	).Scan(&assetTypeID, &s.MaxSupply)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return s, ErrAssetNotFound
+		}
+		return s, err
+	}
+
+	err = tx.QueryRow(ctx, `
+		SELECT COALESCE(-SUM(w.balance) FILTER (WHERE s.role = 'issuance'), 0),
+		       COALESCE(SUM(w.balance) FILTER (WHERE s.role = 'treasury'), 0),
+		       COALESCE(SUM(w.balance) FILTER (WHERE s.role = 'revenue'), 0),
+		       COALESCE(SUM(w.balance) FILTER (WHERE s.role = 'adjustment'), 0),
+		       COALESCE(SUM(w.balance) FILTER (WHERE s.role IS NULL), 0)
+		FROM wallets w
+		LEFT JOIN system_wallets s ON s.wallet_id = w.id
+		WHERE w.asset_type_id = $1
+	`, assetTypeID).Scan(&s.Issued, &s.Treasury, &s.Revenue, &s.Adjustment, &s.Circulating)
+	if err != nil {
+		return s, err
+	}
+
+	err = tx.QueryRow(ctx, `
+		SELECT COALESCE(SUM(e.amount) FILTER (WHERE t.type = $2 AND e.direction = 'debit'), 0),
+		       COALESCE(SUM(e.amount) FILTER (WHERE t.type = $3 AND e.direction = 'credit'), 0)
+		FROM system_wallets s
+		JOIN ledger_entries e ON e.wallet_id = s.wallet_id
+		JOIN transactions t ON t.id = e.transaction_id
+		WHERE s.asset_type_id = $1 AND s.role = 'issuance'
+	`, assetTypeID, TxTypeMint, TxTypeBurn).Scan(&s.Minted, &s.Burned)
+	if err != nil {
+		return s, err
+	}
+
+	s.Balanced = s.Issued == s.Minted-s.Burned &&
+		s.Issued == s.Treasury+s.Revenue+s.Adjustment+s.Circulating
+
+	return s, nil
+}
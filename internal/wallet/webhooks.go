@@ -0,0 +1,470 @@
+package wallet
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	DeliveryStatusPending   = "pending"
+	DeliveryStatusDelivered = "delivered"
+	DeliveryStatusFailed    = "failed"
+)
+
+type WebhookSubscription struct {
+	ID         uuid.UUID `json:"id"`
+	URL        string    `json:"url"`
+	Secret     string    `json:"secret,omitempty"`
+	EventTypes []string  `json:"event_types"`
+	Active     bool      `json:"active"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type WebhookDelivery struct {
+	ID             uuid.UUID  `json:"id"`
+	SubscriptionID uuid.UUID  `json:"subscription_id"`
+	EventID        uuid.UUID  `json:"event_id"`
+	EventType      string     `json:"event_type"`
+	Status         string     `json:"status"`
+	Attempts       int        `json:"attempts"`
+	LastStatusCode *int       `json:"last_status_code"`
+	LastError      *string    `json:"last_error"`
+	NextAttemptAt  time.Time  `json:"next_attempt_at"`
+	DeliveredAt    *time.Time `json:"delivered_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+
+	Payload json.RawMessage `json:"payload,omitempty"`
+
+	AttemptLog []WebhookAttempt `json:"attempt_log,omitempty"`
+}
+
+type WebhookAttempt struct {
+	StatusCode  *int      `json:"status_code"`
+	Error       *string   `json:"error"`
+	DurationMS  int       `json:"duration_ms"`
+	AttemptedAt time.Time `json:"attempted_at"`
+}
+
+// WebhookDispatch is a claimed delivery with what is needed to send it.
+type WebhookDispatch struct {
+	DeliveryID uuid.UUID
+	EventID    uuid.UUID
+	EventType  string
+	URL        string
+	Secret     string
+	Payload    []byte
+	Attempts   int
+}
+
+// webhookEventTypes are the event types a subscription can filter on.
+var webhookEventTypes = map[string]bool{
+	ledgerEventType(TxTypeTopup):      true,
+	ledgerEventType(TxTypeBonus):      true,
+	ledgerEventType(TxTypeSpend):      true,
+	ledgerEventType(TxTypeTransfer):   true,
+	ledgerEventType(TxTypeSweep):      true,
+	ledgerEventType(TxTypeReversal):   true,
+	ledgerEventType(TxTypeCapture):    true,
+	ledgerEventType(TxTypeAdjustment): true,
+	ledgerEventType(TxTypeClosure):    true,
+	ledgerEventType(TxTypeMint):       true,
+	ledgerEventType(TxTypeBurn):       true,
+}
+
+func validateWebhook(rawURL string, eventTypes []string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%w: url must be an absolute http(s) url", ErrInvalidWebhook)
+	}
+	for _, t := range eventTypes {
+		if !webhookEventTypes[t] {
+			return fmt.Errorf("%w: unknown event type %q", ErrInvalidWebhook, t)
+		}
+	}
+	return nil
+}
+
+func newWebhookSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
+
+// CreateWebhook registers a subscription and returns it with its signing
+// secret, which is not returned by any other call.
+func (r *Repository) CreateWebhook(
+	ctx context.Context,
+	rawURL string,
+	eventTypes []string,
+) (WebhookSubscription, error) {
+
+	if eventTypes == nil {
+		eventTypes = []string{}
+	}
+	if err := validateWebhook(rawURL, eventTypes); err != nil {
+		return WebhookSubscription{}, err
+	}
+
+	secret, err := newWebhookSecret()
+	if err != nil {
+		return WebhookSubscription{}, err
+	}
+
+	w := WebhookSubscription{
+		ID:         uuid.New(),
+		URL:        rawURL,
+		Secret:     secret,
+		EventTypes: eventTypes,
+		Active:     true,
+	}
+
+	err = r.pool.QueryRow(ctx, `
+		INSERT INTO webhook_subscriptions (id, url, secret, event_types)
+		VALUES ($1, $2, $3, $4)
+		RETURNING created_at
+	`, w.ID, w.URL, w.Secret, w.EventTypes).Scan(&w.CreatedAt)
+
+	return w, err
+}
+
+func (r *Repository) ListWebhooks(ctx context.Context) ([]WebhookSubscription, error) {
+
+	rows, err := r.pool.Query(ctx, `
+		SELECT id, url, event_types, active, created_at
+		FROM webhook_subscriptions
+		WHERE active
+		ORDER BY created_at, id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	webhooks := []WebhookSubscription{}
+
+	for rows.Next() {
+		var w WebhookSubscription
+		if err := rows.Scan(&w.ID, &w.URL, &w.EventTypes, &w.Active, &w.CreatedAt); err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, w)
+	}
+
+	return webhooks, rows.Err()
+}
+
+// DeleteWebhook deactivates a subscription, its pending deliveries are
+// dropped and its delivery log is kept.
+func (r *Repository) DeleteWebhook(ctx context.Context, id uuid.UUID) error {
+
+	tx, err := r.pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx,
+		`UPDATE webhook_subscriptions SET active = FALSE WHERE id = $1 AND active`,
+		id,
+	)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrWebhookNotFound
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE webhook_deliveries
+		SET status = 'failed', last_error = 'subscription deleted'
+		WHERE subscription_id = $1 AND status = 'pending'
+	`, id)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// EnqueueWebhookDeliveries creates a pending delivery of event for every
+// active subscription it matches. Enqueueing an event again is a no-op.
+func (r *Repository) EnqueueWebhookDeliveries(ctx context.Context, event LedgerEvent) error {
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.pool.Exec(ctx, `
+		INSERT INTO webhook_deliveries (id, subscription_id, event_id, event_type, payload)
+		SELECT gen_random_uuid(), s.id, $1, $2, $3
+		FROM webhook_subscriptions s
+		WHERE s.active
+		  AND (cardinality(s.event_types) = 0 OR $2 = ANY(s.event_types))
+		ON CONFLICT (subscription_id, event_id) DO NOTHING
+	`, event.ID, event.Type, payload)
+
+	return err
+}
+
+// ClaimWebhookDeliveries claims up to limit due pending deliveries of active
+// subscriptions, a claimed delivery is not handed out again for lease.
+func (r *Repository) ClaimWebhookDeliveries(
+	ctx context.Context,
+	limit int,
+	lease time.Duration,
+) ([]WebhookDispatch, error) {
+
+	rows, err := r.pool.Query(ctx, `
+		WITH claimed AS (
+			UPDATE webhook_deliveries d
+			SET next_attempt_at = NOW() + $2 * INTERVAL '1 millisecond'
+			WHERE d.id IN (
+				SELECT id
+				FROM webhook_deliveries
+				WHERE status = 'pending' AND next_attempt_at <= NOW()
+				ORDER BY next_attempt_at
+				LIMIT $1
+				FOR UPDATE SKIP LOCKED
+			)
+			RETURNING d.id, d.subscription_id, d.event_id, d.event_type, d.payload, d.attempts
+		)
+		SELECT c.id, c.event_id, c.event_type, s.url, s.secret, c.payload, c.attempts
+		FROM claimed c
+		JOIN webhook_subscriptions s ON s.id = c.subscription_id
+	`, limit, lease.Milliseconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	dispatches := []WebhookDispatch{}
+
+	for rows.Next() {
+		var d WebhookDispatch
+		if err := rows.Scan(
+			&d.DeliveryID,
+			&d.EventID,
+			&d.EventType,
+			&d.URL,
+			&d.Secret,
+			&d.Payload,
+			&d.Attempts,
+		); err != nil {
+			return nil, err
+		}
+		dispatches = append(dispatches, d)
+	}
+
+	return dispatches, rows.Err()
+}
+
+// RecordWebhookAttempt logs a delivery attempt and moves the delivery on:
+// delivered when it succeeded, pending until retryAt when it failed and
+// retryAt is set, failed otherwise.
+func (r *Repository) RecordWebhookAttempt(
+	ctx context.Context,
+	deliveryID uuid.UUID,
+	statusCode *int,
+	cause error,
+	duration time.Duration,
+	retryAt *time.Time,
+) error {
+
+	var errText *string
+	if cause != nil {
+		s := cause.Error()
+		errText = &s
+	}
+
+	status := DeliveryStatusDelivered
+	if cause != nil {
+		status = DeliveryStatusFailed
+		if retryAt != nil {
+			status = DeliveryStatusPending
+		}
+	}
+
+	tx, err := r.pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO webhook_delivery_attempts (delivery_id, status_code, error, duration_ms)
+		VALUES ($1, $2, $3, $4)
+	`, deliveryID, statusCode, errText, duration.Milliseconds())
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE webhook_deliveries
+		SET status = $2,
+		    attempts = attempts + 1,
+		    last_status_code = $3,
+		    last_error = $4,
+		    next_attempt_at = COALESCE($5, next_attempt_at),
+		    delivered_at = CASE WHEN $2 = 'delivered' THEN NOW() END
+		WHERE id = $1
+	`, deliveryID, status, statusCode, errText, retryAt)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+const webhookDeliveryColumns = `id, subscription_id, event_id, event_type, status,
+	attempts, last_status_code, last_error, next_attempt_at, delivered_at, created_at`
+
+func scanWebhookDelivery(row pgx.Row, extra ...any) (WebhookDelivery, error) {
+	var d WebhookDelivery
+
+	err := row.Scan(append([]any{
+		&d.ID,
+		&d.SubscriptionID,
+		&d.EventID,
+		&d.EventType,
+		&d.Status,
+		&d.Attempts,
+		&d.LastStatusCode,
+		&d.LastError,
+		&d.NextAttemptAt,
+		&d.DeliveredAt,
+		&d.CreatedAt,
+	}, extra...)...)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return d, ErrWebhookDeliveryNotFound
+	}
+
+	return d, err
+}
+
+// ListWebhookDeliveries returns the latest deliveries of a subscription,
+// optionally only those with the given status.
+func (r *Repository) ListWebhookDeliveries(
+	ctx context.Context,
+	subscriptionID uuid.UUID,
+	status *string,
+	limit int,
+) ([]WebhookDelivery, error) {
+
+	var exists bool
+	err := r.pool.QueryRow(ctx,
+		`SELECT EXISTS (SELECT 1 FROM webhook_subscriptions WHERE id = $1)`,
+		subscriptionID,
+	).Scan(&exists)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrWebhookNotFound
+	}
+
+	rows, err := r.pool.Query(ctx, `
+		SELECT `+webhookDeliveryColumns+`
+		FROM webhook_deliveries
+		WHERE subscription_id = $1 AND ($2::text IS NULL OR status = $2)
+		ORDER BY created_at DESC, id DESC
+		LIMIT $3
+	`, subscriptionID, status, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []WebhookDelivery{}
+
+	for rows.Next() {
+		d, err := scanWebhookDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+
+	return deliveries, rows.Err()
+}
+
+// GetWebhookDelivery returns a delivery with its payload and attempt log.
+func (r *Repository) GetWebhookDelivery(ctx context.Context, id uuid.UUID) (WebhookDelivery, error) {
+
+	var payload []byte
+	d, err := scanWebhookDelivery(r.pool.QueryRow(ctx, `
+		SELECT `+webhookDeliveryColumns+`, payload
+		FROM webhook_deliveries
+		WHERE id = $1
+	`, id), &payload)
+	if err != nil {
+		return d, err
+	}
+	d.Payload = payload
+
+	rows, err := r.pool.Query(ctx, `
+		SELECT status_code, error, duration_ms, attempted_at
+		FROM webhook_delivery_attempts
+		WHERE delivery_id = $1
+		ORDER BY id
+	`, id)
+	if err != nil {
+		return d, err
+	}
+	defer rows.Close()
+
+	d.AttemptLog = []WebhookAttempt{}
+
+	for rows.Next() {
+		var a WebhookAttempt
+		if err := rows.Scan(&a.StatusCode, &a.Error, &a.DurationMS, &a.AttemptedAt); err != nil {
+			return d, err
+		}
+		d.AttemptLog = append(d.AttemptLog, a)
+	}
+
+	return d, rows.Err()
+}
+
+// ReplayWebhookDelivery queues a delivery to be sent again right away,
+// whatever its status. Its attempt count restarts the retry schedule.
+func (r *Repository) ReplayWebhookDelivery(ctx context.Context, id uuid.UUID) (WebhookDelivery, error) {
+
+	d, err := scanWebhookDelivery(r.pool.QueryRow(ctx, `
+		UPDATE webhook_deliveries d
+		SET status = 'pending', attempts = 0, next_attempt_at = NOW(), delivered_at = NULL
+		FROM webhook_subscriptions s
+		WHERE d.id = $1 AND s.id = d.subscription_id AND s.active
+		RETURNING d.id, d.subscription_id, d.event_id, d.event_type, d.status,
+			d.attempts, d.last_status_code, d.last_error, d.next_attempt_at,
+			d.delivered_at, d.created_at
+	`,
+		id,
+	))
+	if errors.Is(err, ErrWebhookDeliveryNotFound) {
+		// an existing delivery of a deleted subscription cannot be replayed
+		var subscriptionActive bool
+		qerr := r.pool.QueryRow(ctx, `
+			SELECT s.active
+			FROM webhook_deliveries d
+			JOIN webhook_subscriptions s ON s.id = d.subscription_id
+			WHERE d.id = $1
+		`, id).Scan(&subscriptionActive)
+		if qerr == nil {
+			return d, fmt.Errorf("subscription is deleted: %w", ErrWebhookNotFound)
+		}
+	}
+
+	return d, err
+}
@@ -0,0 +1,133 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"wallet-service/internal/wallet"
+)
+
+const (
+	batchSize = 50
+
+	// lease must outlast sending a whole batch at the client timeout.
+	lease = 15 * time.Minute
+
+	// MaxAttempts is how often a delivery is tried before it is marked
+	// failed, after that only a manual replay sends it again.
+	MaxAttempts = 10
+
+	baseBackoff = 10 * time.Second
+	maxBackoff  = 6 * time.Hour
+)
+
+// Store is the webhook side of the wallet repository.
+type Store interface {
+	EnqueueWebhookDeliveries(ctx context.Context, event wallet.LedgerEvent) error
+	ClaimWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) ([]wallet.WebhookDispatch, error)
+	RecordWebhookAttempt(
+		ctx context.Context,
+		deliveryID uuid.UUID,
+		statusCode *int,
+		cause error,
+		duration time.Duration,
+		retryAt *time.Time,
+	) error
+}
+
+// FanoutSink is an outbox sink that queues a webhook delivery of every
+// ledger event for each subscription it matches.
+type FanoutSink struct {
+	store Store
+}
+
+func NewFanoutSink(store Store) *FanoutSink {
+	return &FanoutSink{store: store}
+}
+
+func (s *FanoutSink) Publish(ctx context.Context, event wallet.LedgerEvent) error {
+	return s.store.EnqueueWebhookDeliveries(ctx, event)
+}
+
+// Deliverer sends queued webhook deliveries, signed with the secret of their
+// subscription, and retries failures with exponential backoff.
+type Deliverer struct {
+	store  Store
+	client *http.Client
+}
+
+func NewDeliverer(store Store, client *http.Client) *Deliverer {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &Deliverer{store: store, client: client}
+}
+
+// Deliver sends due deliveries batch by batch until none are left.
+func (d *Deliverer) Deliver(ctx context.Context) error {
+	for {
+		dispatches, err := d.store.ClaimWebhookDeliveries(ctx, batchSize, lease)
+		if err != nil {
+			return err
+		}
+
+		for _, w := range dispatches {
+			start := time.Now()
+			statusCode, sendErr := d.send(ctx, w)
+			duration := time.Since(start)
+
+			var retryAt *time.Time
+			if sendErr != nil && w.Attempts+1 < MaxAttempts {
+				at := time.Now().Add(backoff(w.Attempts))
+				retryAt = &at
+			}
+
+			err := d.store.RecordWebhookAttempt(ctx, w.DeliveryID, statusCode, sendErr, duration, retryAt)
+			if err != nil {
+				return err
+			}
+		}
+
+		if len(dispatches) < batchSize {
+			return nil
+		}
+	}
+}
+
+func (d *Deliverer) send(ctx context.Context, w wallet.WebhookDispatch) (*int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(w.Payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Webhook-Id", w.EventID.String())
+	req.Header.Set("Webhook-Event", w.EventType)
+	req.Header.Set(SignatureHeader, Sign(w.Secret, time.Now(), w.Payload))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	statusCode := resp.StatusCode
+	if statusCode < 200 || statusCode > 299 {
+		return &statusCode, fmt.Errorf("endpoint responded %d", statusCode)
+	}
+
+	return &statusCode, nil
+}
+
+// backoff doubles from baseBackoff with every failed attempt.
+func backoff(attempts int) time.Duration {
+	if attempts >= 16 {
+		return maxBackoff
+	}
+	return min(baseBackoff<<attempts, maxBackoff)
+}
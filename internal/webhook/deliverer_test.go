@@ -0,0 +1,152 @@
+package webhook
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"wallet-service/internal/wallet"
+)
+
+type attempt struct {
+	deliveryID uuid.UUID
+	statusCode *int
+	cause      error
+	retryAt    *time.Time
+}
+
+// fakeStore hands out its dispatches once and records every attempt.
+type fakeStore struct {
+	dispatches []wallet.WebhookDispatch
+	attempts   []attempt
+}
+
+func (s *fakeStore) EnqueueWebhookDeliveries(ctx context.Context, event wallet.LedgerEvent) error {
+	return nil
+}
+
+func (s *fakeStore) ClaimWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) ([]wallet.WebhookDispatch, error) {
+	d := s.dispatches
+	s.dispatches = nil
+	return d, nil
+}
+
+func (s *fakeStore) RecordWebhookAttempt(
+	ctx context.Context,
+	deliveryID uuid.UUID,
+	statusCode *int,
+	cause error,
+	duration time.Duration,
+	retryAt *time.Time,
+) error {
+	s.attempts = append(s.attempts, attempt{deliveryID, statusCode, cause, retryAt})
+	return nil
+}
+
+func deliverOnce(t *testing.T, status int, attempts int) attempt {
+	t.Helper()
+
+	const secret = "whsec_test"
+	payload := []byte(`{"type":"ledger.topup"}`)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if err := Verify(secret, r.Header.Get(SignatureHeader), body, time.Minute); err != nil {
+			t.Errorf("delivery signature: %v", err)
+		}
+		if got := r.Header.Get("Webhook-Event"); got != "ledger.topup" {
+			t.Errorf("Webhook-Event = %q, want ledger.topup", got)
+		}
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	store := &fakeStore{dispatches: []wallet.WebhookDispatch{{
+		DeliveryID: uuid.New(),
+		EventID:    uuid.New(),
+		EventType:  "ledger.topup",
+		URL:        srv.URL,
+		Secret:     secret,
+		Payload:    payload,
+		Attempts:   attempts,
+	}}}
+
+	if err := NewDeliverer(store, srv.Client()).Deliver(context.Background()); err != nil {
+		t.Fatalf("Deliver() = %v", err)
+	}
+	if len(store.attempts) != 1 {
+		t.Fatalf("recorded %d attempts, want 1", len(store.attempts))
+	}
+
+	return store.attempts[0]
+}
+
+func TestDeliverSuccess(t *testing.T) {
+	a := deliverOnce(t, http.StatusNoContent, 0)
+
+	if a.cause != nil {
+		t.Fatalf("cause = %v, want nil", a.cause)
+	}
+	if a.statusCode == nil || *a.statusCode != http.StatusNoContent {
+		t.Fatalf("status code = %v, want %d", a.statusCode, http.StatusNoContent)
+	}
+	if a.retryAt != nil {
+		t.Fatalf("retry scheduled at %v for a delivered webhook", a.retryAt)
+	}
+}
+
+func TestDeliverServerErrorSchedulesRetry(t *testing.T) {
+	const attempts = 3
+	before := time.Now()
+
+	a := deliverOnce(t, http.StatusBadGateway, attempts)
+
+	if a.cause == nil {
+		t.Fatal("cause = nil, want an error")
+	}
+	if a.statusCode == nil || *a.statusCode != http.StatusBadGateway {
+		t.Fatalf("status code = %v, want %d", a.statusCode, http.StatusBadGateway)
+	}
+	if a.retryAt == nil {
+		t.Fatal("no retry scheduled")
+	}
+
+	wait := backoff(attempts)
+	if a.retryAt.Before(before.Add(wait)) || a.retryAt.After(time.Now().Add(wait)) {
+		t.Fatalf("retry at %v, want %v after the attempt", a.retryAt, wait)
+	}
+}
+
+func TestDeliverLastAttemptFails(t *testing.T) {
+	a := deliverOnce(t, http.StatusInternalServerError, MaxAttempts-1)
+
+	if a.cause == nil {
+		t.Fatal("cause = nil, want an error")
+	}
+	if a.retryAt != nil {
+		t.Fatalf("retry scheduled at %v after the last attempt", a.retryAt)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{0, baseBackoff},
+		{1, 2 * baseBackoff},
+		{3, 8 * baseBackoff},
+		{16, maxBackoff},
+		{64, maxBackoff},
+	}
+
+	for _, tt := range tests {
+		if got := backoff(tt.attempts); got != tt.want {
+			t.Errorf("backoff(%d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
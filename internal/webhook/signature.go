@@ -0,0 +1,69 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SignatureHeader carries "t=<unix seconds>,v1=<hex hmac>". The HMAC-SHA256
+// is keyed with the subscription secret and taken over "<t>.<body>", so a
+// receiver can reject both forged and replayed requests.
+const SignatureHeader = "Webhook-Signature"
+
+var ErrInvalidSignature = errors.New("invalid webhook signature")
+
+func sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Sign returns the signature header value of body sent at t.
+func Sign(secret string, t time.Time, body []byte) string {
+	ts := t.Unix()
+	return "t=" + strconv.FormatInt(ts, 10) + ",v1=" + sign(secret, ts, body)
+}
+
+// Verify checks a signature header against body, rejecting signatures older
+// than tolerance. Receivers can use it as is.
+func Verify(secret string, header string, body []byte, tolerance time.Duration) error {
+	var ts int64
+	var sig string
+
+	for _, part := range strings.Split(header, ",") {
+		k, v, ok := strings.Cut(part, "=")
+		if !ok {
+			return ErrInvalidSignature
+		}
+		switch k {
+		case "t":
+			n, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return ErrInvalidSignature
+			}
+			ts = n
+		case "v1":
+			sig = v
+		}
+	}
+	if ts == 0 || sig == "" {
+		return ErrInvalidSignature
+	}
+
+	if age := time.Since(time.Unix(ts, 0)); age > tolerance || age < -tolerance {
+		return ErrInvalidSignature
+	}
+
+	if !hmac.Equal([]byte(sig), []byte(sign(secret, ts, body))) {
+		return ErrInvalidSignature
+	}
+
+	return nil
+}
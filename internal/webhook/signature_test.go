@@ -0,0 +1,38 @@
+package webhook
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSignVerify(t *testing.T) {
+	const secret = "whsec_test"
+	body := []byte(`{"type":"ledger.topup","amount":100}`)
+	now := time.Now()
+
+	tests := []struct {
+		name   string
+		secret string
+		header string
+		body   []byte
+		want   error
+	}{
+		{"valid", secret, Sign(secret, now, body), body, nil},
+		{"tampered body", secret, Sign(secret, now, body), []byte(`{"type":"ledger.topup","amount":999}`), ErrInvalidSignature},
+		{"wrong secret", "whsec_other", Sign(secret, now, body), body, ErrInvalidSignature},
+		{"stale timestamp", secret, Sign(secret, now.Add(-10*time.Minute), body), body, ErrInvalidSignature},
+		{"future timestamp", secret, Sign(secret, now.Add(10*time.Minute), body), body, ErrInvalidSignature},
+		{"missing signature", secret, "t=1700000000", body, ErrInvalidSignature},
+		{"malformed header", secret, "garbage", body, ErrInvalidSignature},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Verify(tt.secret, tt.header, tt.body, 5*time.Minute)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("Verify() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
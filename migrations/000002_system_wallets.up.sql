@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS system_wallets (
+    asset_type_id INT NOT NULL REFERENCES assets(id),
+    role TEXT NOT NULL,
+    wallet_id UUID NOT NULL UNIQUE REFERENCES wallets(id),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (asset_type_id, role)
+);
+
+-- register system wallets of databases seeded before this migration
+INSERT INTO system_wallets (asset_type_id, role, wallet_id)
+SELECT w.asset_type_id, v.role, w.id
+FROM (VALUES
+    ('00000000-0000-0000-0000-000000000000'::uuid, 'treasury'),
+    ('00000000-0000-0000-0000-000000000001'::uuid, 'treasury'),
+    ('00000000-0000-0000-0000-000000000002'::uuid, 'revenue'),
+    ('00000000-0000-0000-0000-000000000003'::uuid, 'revenue')
+) AS v(wallet_id, role)
+JOIN wallets w ON w.id = v.wallet_id
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,4 @@
+ALTER TABLE transactions
+    DROP COLUMN IF EXISTS amount,
+    DROP COLUMN IF EXISTS to_wallet_id,
+    DROP COLUMN IF EXISTS from_wallet_id;
@@ -0,0 +1,17 @@
+ALTER TABLE transactions
+    ADD COLUMN IF NOT EXISTS from_wallet_id UUID NULL REFERENCES wallets(id),
+    ADD COLUMN IF NOT EXISTS to_wallet_id UUID NULL REFERENCES wallets(id),
+    ADD COLUMN IF NOT EXISTS amount BIGINT NULL;
+
+-- backfill request parameters of existing two legged transactions from the ledger
+UPDATE transactions t
+SET from_wallet_id = d.wallet_id,
+    to_wallet_id = c.wallet_id,
+    amount = d.amount
+FROM ledger_entries d, ledger_entries c
+WHERE d.transaction_id = t.id
+  AND d.direction = 'debit'
+  AND c.transaction_id = t.id
+  AND c.direction = 'credit'
+  AND t.from_wallet_id IS NULL
+  AND (SELECT COUNT(*) FROM ledger_entries e WHERE e.transaction_id = t.id) = 2;
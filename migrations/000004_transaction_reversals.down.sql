@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_transactions_reverses_transaction_id;
+
+ALTER TABLE transactions
+    DROP COLUMN IF EXISTS reversed_amount,
+    DROP COLUMN IF EXISTS reverses_transaction_id;
@@ -0,0 +1,7 @@
+ALTER TABLE transactions
+    ADD COLUMN IF NOT EXISTS reverses_transaction_id UUID NULL REFERENCES transactions(id),
+    ADD COLUMN IF NOT EXISTS reversed_amount BIGINT NOT NULL DEFAULT 0 CHECK (reversed_amount >= 0);
+
+CREATE INDEX IF NOT EXISTS idx_transactions_reverses_transaction_id
+ON transactions(reverses_transaction_id)
+WHERE reverses_transaction_id IS NOT NULL;
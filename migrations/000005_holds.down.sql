@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_holds_active_wallet;
+DROP TABLE IF EXISTS holds;
@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS holds (
+    id UUID PRIMARY KEY,
+    reference_id TEXT UNIQUE NOT NULL,
+    wallet_id UUID NOT NULL REFERENCES wallets(id),
+    amount BIGINT NOT NULL CHECK (amount > 0),
+    captured_amount BIGINT NOT NULL DEFAULT 0 CHECK (captured_amount >= 0),
+    status TEXT NOT NULL DEFAULT 'active',
+    capture_transaction_id UUID NULL REFERENCES transactions(id),
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- active holds are summed on every debit to derive the available balance
+CREATE INDEX IF NOT EXISTS idx_holds_active_wallet
+ON holds(wallet_id, expires_at)
+WHERE status = 'active';
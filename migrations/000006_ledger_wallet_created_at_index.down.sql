@@ -0,0 +1 @@
+DROP INDEX IF EXISTS idx_ledger_wallet_created_at;
@@ -0,0 +1,3 @@
+-- per wallet history reads (statements, point in time balances) walk entries by time
+CREATE INDEX IF NOT EXISTS idx_ledger_wallet_created_at
+ON ledger_entries(wallet_id, created_at, id);
@@ -0,0 +1,6 @@
+-- keyset pagination walks both listings by (created_at, id) newest first
+CREATE INDEX IF NOT EXISTS idx_transactions_created_at_id
+ON transactions(created_at DESC, id DESC);
+
+CREATE INDEX IF NOT EXISTS idx_ledger_created_at_id
+ON ledger_entries(created_at DESC, id DESC);
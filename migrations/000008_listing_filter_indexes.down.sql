@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_transactions_type_created_at;
+DROP INDEX IF EXISTS idx_transactions_reference_id_pattern;
@@ -0,0 +1,6 @@
+-- reference_id prefix filters need pattern ops under non C collations
+CREATE INDEX IF NOT EXISTS idx_transactions_reference_id_pattern
+ON transactions(reference_id text_pattern_ops);
+
+CREATE INDEX IF NOT EXISTS idx_transactions_type_created_at
+ON transactions(type, created_at DESC);
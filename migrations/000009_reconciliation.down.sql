@@ -0,0 +1,6 @@
+ALTER TABLE wallets DROP CONSTRAINT IF EXISTS wallets_balance_check;
+
+ALTER TABLE wallets
+    ADD CONSTRAINT wallets_balance_check CHECK (balance >= 0);
+
+ALTER TABLE wallets DROP COLUMN IF EXISTS allow_negative;
@@ -0,0 +1,9 @@
+-- system contra wallets (such as reconciliation adjustments) carry the
+-- negative side of entries that have no real counterparty
+ALTER TABLE wallets
+    ADD COLUMN IF NOT EXISTS allow_negative BOOLEAN NOT NULL DEFAULT FALSE;
+
+ALTER TABLE wallets DROP CONSTRAINT IF EXISTS wallets_balance_check;
+
+ALTER TABLE wallets
+    ADD CONSTRAINT wallets_balance_check CHECK (balance >= 0 OR allow_negative);
@@ -0,0 +1,4 @@
+CREATE INDEX IF NOT EXISTS idx_ledger_wallet_created_at
+ON ledger_entries(wallet_id, created_at, id);
+
+DROP INDEX IF EXISTS idx_ledger_wallet_created_at_covering;
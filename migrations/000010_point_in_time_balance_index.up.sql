@@ -0,0 +1,5 @@
+-- covering index so as_of balances sum a wallet's entries with an index only scan
+CREATE INDEX IF NOT EXISTS idx_ledger_wallet_created_at_covering
+ON ledger_entries(wallet_id, created_at, id) INCLUDE (direction, amount);
+
+DROP INDEX IF EXISTS idx_ledger_wallet_created_at;
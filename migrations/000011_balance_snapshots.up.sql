@@ -0,0 +1,11 @@
+-- end of day (UTC) balance of every wallet, derived from the ledger
+CREATE TABLE IF NOT EXISTS wallet_balance_snapshots (
+    wallet_id UUID NOT NULL REFERENCES wallets(id),
+    day DATE NOT NULL,
+    balance BIGINT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (wallet_id, day)
+);
+
+CREATE INDEX IF NOT EXISTS idx_wallet_balance_snapshots_day
+ON wallet_balance_snapshots(day);
@@ -0,0 +1,18 @@
+-- ledger events written in the same transaction as the journal they
+-- describe, published to the configured sink by the outbox dispatcher
+CREATE TABLE IF NOT EXISTS outbox_events (
+    id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    event_id UUID NOT NULL UNIQUE,
+    event_type TEXT NOT NULL,
+    transaction_id UUID NOT NULL REFERENCES transactions(id),
+    payload JSONB NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    attempts INT NOT NULL DEFAULT 0,
+    last_error TEXT,
+    next_attempt_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    delivered_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_outbox_events_pending
+ON outbox_events(next_attempt_at, id)
+WHERE delivered_at IS NULL;
@@ -0,0 +1,13 @@
+-- the wallets each ledger event touches, so a wallet's event stream is an
+-- index range scan
+CREATE TABLE IF NOT EXISTS outbox_event_wallets (
+    wallet_id UUID NOT NULL,
+    event_sequence BIGINT NOT NULL REFERENCES outbox_events(id),
+    PRIMARY KEY (wallet_id, event_sequence)
+);
+
+INSERT INTO outbox_event_wallets (wallet_id, event_sequence)
+SELECT DISTINCT (b->>'wallet_id')::uuid, e.id
+FROM outbox_events e,
+     jsonb_array_elements(e.payload->'balances') b
+ON CONFLICT DO NOTHING;
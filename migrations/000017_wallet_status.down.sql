@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS wallet_status_changes;
+
+ALTER TABLE wallets
+    DROP COLUMN IF EXISTS block_credits,
+    DROP COLUMN IF EXISTS status;
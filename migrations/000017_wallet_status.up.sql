@@ -0,0 +1,21 @@
+ALTER TABLE wallets
+    ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'active'
+        CHECK (status IN ('active', 'frozen', 'closed')),
+    -- a frozen wallet always rejects debits, credits only when set
+    ADD COLUMN IF NOT EXISTS block_credits BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- who changed the status of a wallet and why
+CREATE TABLE IF NOT EXISTS wallet_status_changes (
+    id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    wallet_id UUID NOT NULL REFERENCES wallets(id),
+    from_status TEXT NOT NULL,
+    to_status TEXT NOT NULL,
+    block_credits BOOLEAN NOT NULL,
+    actor TEXT NOT NULL,
+    reason TEXT NOT NULL,
+    closing_transaction_id UUID REFERENCES transactions(id),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_wallet_status_changes_wallet
+ON wallet_status_changes(wallet_id, id);
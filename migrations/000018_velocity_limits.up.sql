@@ -0,0 +1,25 @@
+-- velocity limits cap what a user wallet moves per rolling window. Asset
+-- wide defaults have asset_type_id set, per wallet overrides wallet_id.
+CREATE TABLE IF NOT EXISTS velocity_limits (
+    id UUID PRIMARY KEY,
+    asset_type_id INT REFERENCES assets(id),
+    wallet_id UUID REFERENCES wallets(id),
+    tx_type TEXT NOT NULL,
+    direction entry_direction NOT NULL,
+    period TEXT NOT NULL CHECK (period IN ('hour', 'day', 'week', 'month')),
+    max_amount BIGINT CHECK (max_amount > 0),
+    max_count BIGINT CHECK (max_count > 0),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    CHECK ((asset_type_id IS NULL) <> (wallet_id IS NULL)),
+    -- a wallet override without maximums lifts the asset default
+    CHECK (wallet_id IS NOT NULL OR max_amount IS NOT NULL OR max_count IS NOT NULL)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS unique_velocity_limit_asset
+ON velocity_limits(asset_type_id, tx_type, direction, period)
+WHERE asset_type_id IS NOT NULL;
+
+CREATE UNIQUE INDEX IF NOT EXISTS unique_velocity_limit_wallet
+ON velocity_limits(wallet_id, tx_type, direction, period)
+WHERE wallet_id IS NOT NULL;
@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS idx_wallets_negative_balance;
+
+ALTER TABLE wallets DROP CONSTRAINT IF EXISTS wallets_balance_check;
+
+ALTER TABLE wallets
+    ADD CONSTRAINT wallets_balance_check CHECK (balance >= 0 OR allow_negative);
+
+ALTER TABLE wallets DROP COLUMN IF EXISTS overdraft_limit;
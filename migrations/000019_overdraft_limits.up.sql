@@ -0,0 +1,16 @@
+-- a wallet may go as far below zero as its overdraft limit, contra wallets
+-- without bound
+ALTER TABLE wallets
+    ADD COLUMN IF NOT EXISTS overdraft_limit BIGINT NOT NULL DEFAULT 0
+        CHECK (overdraft_limit >= 0);
+
+ALTER TABLE wallets DROP CONSTRAINT IF EXISTS wallets_balance_check;
+
+ALTER TABLE wallets
+    ADD CONSTRAINT wallets_balance_check
+        CHECK (balance >= -overdraft_limit OR allow_negative);
+
+-- negative balance report
+CREATE INDEX IF NOT EXISTS idx_wallets_negative_balance
+ON wallets(asset_type_id, balance)
+WHERE balance < 0;
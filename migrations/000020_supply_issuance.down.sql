@@ -0,0 +1,27 @@
+-- only the opening mints can be removed, any later mint or burn keeps the
+-- issuance wallets referenced and fails this migration
+UPDATE wallet_balance_snapshots s
+SET balance = s.balance - t.amount
+FROM transactions t
+WHERE t.id IN ('d0000000-0000-0000-0000-000000000001', 'd0000000-0000-0000-0000-000000000002')
+  AND s.wallet_id = t.to_wallet_id;
+
+DELETE FROM ledger_entries
+WHERE transaction_id IN ('d0000000-0000-0000-0000-000000000001', 'd0000000-0000-0000-0000-000000000002');
+
+DELETE FROM transactions
+WHERE id IN ('d0000000-0000-0000-0000-000000000001', 'd0000000-0000-0000-0000-000000000002');
+
+DELETE FROM wallet_balance_snapshots
+WHERE wallet_id IN (SELECT wallet_id FROM system_wallets WHERE role = 'issuance');
+
+CREATE TEMPORARY TABLE issuance_wallets AS
+SELECT wallet_id FROM system_wallets WHERE role = 'issuance';
+
+DELETE FROM system_wallets WHERE role = 'issuance';
+
+DELETE FROM wallets WHERE id IN (SELECT wallet_id FROM issuance_wallets);
+
+DROP TABLE issuance_wallets;
+
+ALTER TABLE assets DROP COLUMN IF EXISTS max_supply;
@@ -0,0 +1,57 @@
+-- optional cap on the supply of an asset issued from its issuance wallet
+ALTER TABLE assets
+    ADD COLUMN IF NOT EXISTS max_supply BIGINT CHECK (max_supply > 0);
+
+-- databases seeded before this migration hold treasury balances without
+-- ledger entries, back them with an opening mint from an issuance wallet
+CREATE TEMPORARY TABLE seeded_issuance AS
+SELECT v.*, w.asset_type_id, w.created_at, a.code,
+       w.balance - COALESCE((
+           SELECT SUM(CASE WHEN e.direction = 'credit' THEN e.amount ELSE -e.amount END)
+           FROM ledger_entries e
+           WHERE e.wallet_id = w.id
+       ), 0) AS unbacked
+FROM (VALUES
+    ('00000000-0000-0000-0000-000000000000'::uuid, '00000000-0000-0000-0000-000000000004'::uuid,
+     'd0000000-0000-0000-0000-000000000001'::uuid, 'gold_initial_issuance',
+     'a0000000-0000-0000-0000-000000000011'::uuid, 'a0000000-0000-0000-0000-000000000012'::uuid),
+    ('00000000-0000-0000-0000-000000000001'::uuid, '00000000-0000-0000-0000-000000000005'::uuid,
+     'd0000000-0000-0000-0000-000000000002'::uuid, 'diamond_initial_issuance',
+     'a0000000-0000-0000-0000-000000000021'::uuid, 'a0000000-0000-0000-0000-000000000022'::uuid)
+) AS v(treasury_id, issuance_id, transaction_id, reference_id, debit_entry_id, credit_entry_id)
+JOIN wallets w ON w.id = v.treasury_id
+JOIN assets a ON a.id = w.asset_type_id
+WHERE NOT EXISTS (
+    SELECT 1 FROM system_wallets s
+    WHERE s.asset_type_id = w.asset_type_id AND s.role = 'issuance'
+);
+
+DELETE FROM seeded_issuance WHERE unbacked <= 0;
+
+INSERT INTO wallets (id, label, user_id, asset_type_id, balance, allow_negative, created_at)
+SELECT issuance_id, 'Issuance ' || code, NULL, asset_type_id, -unbacked, TRUE, created_at
+FROM seeded_issuance;
+
+INSERT INTO system_wallets (asset_type_id, role, wallet_id)
+SELECT asset_type_id, 'issuance', issuance_id
+FROM seeded_issuance;
+
+INSERT INTO transactions
+    (id, reference_id, type, status, from_wallet_id, to_wallet_id, amount, created_at)
+SELECT transaction_id, reference_id, 'mint', 'completed', issuance_id, treasury_id, unbacked, created_at
+FROM seeded_issuance;
+
+INSERT INTO ledger_entries (id, transaction_id, wallet_id, direction, amount, created_at)
+SELECT debit_entry_id, transaction_id, issuance_id, 'debit'::entry_direction, unbacked, created_at
+FROM seeded_issuance
+UNION ALL
+SELECT credit_entry_id, transaction_id, treasury_id, 'credit'::entry_direction, unbacked, created_at
+FROM seeded_issuance;
+
+-- snapshots were derived from the ledger without the opening mint
+UPDATE wallet_balance_snapshots s
+SET balance = s.balance + i.unbacked
+FROM seeded_issuance i
+WHERE s.wallet_id = i.treasury_id;
+
+DROP TABLE seeded_issuance;
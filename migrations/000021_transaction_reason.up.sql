@@ -0,0 +1,5 @@
+-- why an administrative transaction, such as a ledger adjustment, was
+-- posted and by whom
+ALTER TABLE transactions
+    ADD COLUMN IF NOT EXISTS reason TEXT,
+    ADD COLUMN IF NOT EXISTS actor TEXT;
@@ -0,0 +1,3 @@
+ALTER TABLE users
+    DROP COLUMN IF EXISTS block_credits,
+    DROP COLUMN IF EXISTS status;
@@ -0,0 +1,5 @@
+-- a frozen user gets every new wallet frozen the same way
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'active'
+        CHECK (status IN ('active', 'frozen')),
+    ADD COLUMN IF NOT EXISTS block_credits BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,4 @@
+-- set on a reversal requested without an amount, which reversed whatever
+-- remained, so a replay is matched against the same request
+ALTER TABLE transactions
+    ADD COLUMN IF NOT EXISTS reverses_remainder BOOLEAN NOT NULL DEFAULT FALSE;
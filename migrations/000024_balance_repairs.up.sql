@@ -0,0 +1,6 @@
+-- set on the adjustment that recomputed the cached balance of a wallet from
+-- its ledger. The ledger was right, so such a transaction has no entries,
+-- from/to and amount record which way the cached balance moved and by how
+-- much.
+ALTER TABLE transactions
+    ADD COLUMN IF NOT EXISTS repairs_wallet_id UUID REFERENCES wallets(id);
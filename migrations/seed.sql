@@ -17,12 +17,66 @@ INSERT INTO wallets (id, label, user_id, asset_type_id, balance) VALUES
 ('bbbbbbbb-bbbb-bbbb-bbbb-bbbbbbbbbbbb', 'Eren Diamond Wallet', 'e1e1e1e1-e1e1-e1e1-e1e1-e1e1e1e1e1e1', 2, 100)
 ON CONFLICT (id) DO NOTHING;
 
+INSERT INTO system_wallets (asset_type_id, role, wallet_id) VALUES
+(1, 'treasury', '00000000-0000-0000-0000-000000000000'),
+(2, 'treasury', '00000000-0000-0000-0000-000000000001'),
+(2, 'revenue', '00000000-0000-0000-0000-000000000002'),
+(1, 'revenue', '00000000-0000-0000-0000-000000000003')
+ON CONFLICT DO NOTHING;
+
+-- treasury balances are minted through the ledger, so total supply can be
+-- audited. Databases seeded earlier get the same mint from migration 20, or
+-- already have their treasury balances backed, so only fresh ones get it here.
+CREATE TEMPORARY TABLE seed_issuance AS
+SELECT NOT EXISTS (
+    SELECT 1 FROM ledger_entries
+    WHERE wallet_id IN ('00000000-0000-0000-0000-000000000000', '00000000-0000-0000-0000-000000000001')
+      AND transaction_id NOT IN ('d0000000-0000-0000-0000-000000000001', 'd0000000-0000-0000-0000-000000000002')
+) AND NOT EXISTS (
+    SELECT 1 FROM system_wallets
+    WHERE role = 'issuance' AND wallet_id NOT IN ('00000000-0000-0000-0000-000000000004', '00000000-0000-0000-0000-000000000005')
+) AS fresh;
+
+-- issuance wallets carry the negative side of all supply minted into treasury
+INSERT INTO wallets (id, label, user_id, asset_type_id, balance, allow_negative)
+SELECT v.* FROM (VALUES
+('00000000-0000-0000-0000-000000000004'::uuid, 'Issuance Gold', NULL::uuid, 1, -10000000, TRUE),
+('00000000-0000-0000-0000-000000000005'::uuid, 'Issuance Diamond', NULL::uuid, 2, -10000000, TRUE)
+) AS v WHERE (SELECT fresh FROM seed_issuance)
+ON CONFLICT (id) DO NOTHING;
+
+INSERT INTO system_wallets (asset_type_id, role, wallet_id)
+SELECT v.* FROM (VALUES
+(1, 'issuance', '00000000-0000-0000-0000-000000000004'::uuid),
+(2, 'issuance', '00000000-0000-0000-0000-000000000005'::uuid)
+) AS v WHERE (SELECT fresh FROM seed_issuance)
+ON CONFLICT DO NOTHING;
+
+INSERT INTO transactions (id, reference_id, type, status, from_wallet_id, to_wallet_id, amount)
+SELECT v.* FROM (VALUES
+('d0000000-0000-0000-0000-000000000001'::uuid, 'gold_initial_issuance', 'mint', 'completed', '00000000-0000-0000-0000-000000000004'::uuid, '00000000-0000-0000-0000-000000000000'::uuid, 10000000),
+('d0000000-0000-0000-0000-000000000002'::uuid, 'diamond_initial_issuance', 'mint', 'completed', '00000000-0000-0000-0000-000000000005'::uuid, '00000000-0000-0000-0000-000000000001'::uuid, 10000000)
+) AS v WHERE (SELECT fresh FROM seed_issuance)
+ON CONFLICT DO NOTHING;
+
+INSERT INTO ledger_entries (id, transaction_id, wallet_id, direction, amount)
+SELECT v.id, v.transaction_id, v.wallet_id, v.direction::entry_direction, v.amount FROM (VALUES
+('a0000000-0000-0000-0000-000000000011'::uuid, 'd0000000-0000-0000-0000-000000000001'::uuid, '00000000-0000-0000-0000-000000000004'::uuid, 'debit', 10000000),
+('a0000000-0000-0000-0000-000000000012'::uuid, 'd0000000-0000-0000-0000-000000000001'::uuid, '00000000-0000-0000-0000-000000000000'::uuid, 'credit', 10000000),
+
+('a0000000-0000-0000-0000-000000000021'::uuid, 'd0000000-0000-0000-0000-000000000002'::uuid, '00000000-0000-0000-0000-000000000005'::uuid, 'debit', 10000000),
+('a0000000-0000-0000-0000-000000000022'::uuid, 'd0000000-0000-0000-0000-000000000002'::uuid, '00000000-0000-0000-0000-000000000001'::uuid, 'credit', 10000000)
+) AS v(id, transaction_id, wallet_id, direction, amount) WHERE (SELECT fresh FROM seed_issuance)
+ON CONFLICT (id) DO NOTHING;
+
+DROP TABLE seed_issuance;
+
 -- if you want to uncomment the transaction of redeem 10, before running seed.sql for first time, increase revenue diamond wallet balance by 0 + 10 = 10 and reduce eren diamond wallet by 100 - 10 = 90
 
-INSERT INTO transactions (id, reference_id, type, status) VALUES 
-('d1111111-1111-1111-1111-111111111111', 'mikasa_buy_gold', 'purchase', 'completed'),
-('d2222222-2222-2222-2222-222222222222', 'eren_buy_diamond', 'purchase', 'completed')
--- ('d3333333-3333-3333-3333-333333333333', 'eren_redeem_diamond', 'redemption', 'completed')
+INSERT INTO transactions (id, reference_id, type, status, from_wallet_id, to_wallet_id, amount) VALUES 
+('d1111111-1111-1111-1111-111111111111', 'mikasa_buy_gold', 'purchase', 'completed', '00000000-0000-0000-0000-000000000000', 'aaaaaaaa-aaaa-aaaa-aaaa-aaaaaaaaaaaa', 1000),
+('d2222222-2222-2222-2222-222222222222', 'eren_buy_diamond', 'purchase', 'completed', '00000000-0000-0000-0000-000000000001', 'bbbbbbbb-bbbb-bbbb-bbbb-bbbbbbbbbbbb', 100)
+-- ('d3333333-3333-3333-3333-333333333333', 'eren_redeem_diamond', 'redemption', 'completed', 'bbbbbbbb-bbbb-bbbb-bbbb-bbbbbbbbbbbb', '00000000-0000-0000-0000-000000000002', 10)
 ON CONFLICT (id) DO NOTHING;
 
 INSERT INTO ledger_entries (id, transaction_id, wallet_id, direction, amount) VALUES 